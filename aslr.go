@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// addrPrefix starts the line a task prints under --mix-aslr, followed by an
+// address in hex.
+const addrPrefix = "ptrsg-addr: "
+
+// addrEpilogues print, after an interpreted snippet, the address of
+// something freshly allocated, which moves with ASLR from run to run: a
+// table for lua, an object for python (CPython's id is its address), a
+// scalar for perl. node and php don't expose addresses and print nothing.
+var addrEpilogues = map[string]string{
+	"lua":    "print(\"" + addrPrefix + "\" .. (tostring({}):match(\"0x%x+\") or \"\"))\n",
+	"python": "print('" + addrPrefix + "' + hex(id(object())))\n",
+	"perl":   "printf \"" + addrPrefix + "0x%x\\n\", 0 + \\my $__ptrsg_a;\n",
+}
+
+// addrPrologues go at the top of a compiled snippet's main and print the
+// address of a local, which moves with the stack under ASLR. The C and C++
+// ones use __builtin_printf so the snippets needn't include stdio. go's
+// stack lives at a fixed address, and zig and haskell would need imports
+// the snippets don't have, so those print nothing.
+var addrPrologues = map[string][2]string{
+	"c":    {"int main(void) {\n", "    { int ptrsg_a; __builtin_printf(\"" + addrPrefix + "%p\\n\", (void *)&ptrsg_a); }\n"},
+	"cpp":  {"int main() {\n", "    { int ptrsg_a; __builtin_printf(\"" + addrPrefix + "%p\\n\", (void *)&ptrsg_a); }\n"},
+	"rust": {"fn main() {\n", "    { let ptrsg_a = 0u8; println!(\"" + addrPrefix + "{:p}\", &ptrsg_a); }\n"},
+}
+
+// addrPrinting adds lang's address line to code, or returns it unchanged if
+// lang has no way to print one.
+func addrPrinting(lang, code string) string {
+	if e, ok := addrEpilogues[lang]; ok {
+		return code + e
+	}
+	if p, ok := addrPrologues[lang]; ok {
+		return strings.Replace(code, p[0], p[0]+p[1], 1)
+	}
+	return code
+}
+
+// parseAddress finds the last address line in a task's stdout. Any task can
+// take part, --extra-cmd and manifest ones included, by printing one.
+func parseAddress(out []byte) (int64, bool) {
+	var addr uint64
+	found := false
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		rest, ok := strings.CutPrefix(sc.Text(), addrPrefix)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), "0x")
+		if n, err := strconv.ParseUint(rest, 16, 64); err == nil {
+			addr, found = n, true
+		}
+	}
+	return int64(addr), found
+}
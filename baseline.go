@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// calibrationLoops is how many rounds calibrate's loop runs: the same
+// arithmetic as the arith workload, repeated long enough to take tens of
+// milliseconds on a typical machine.
+const calibrationLoops = 5_000_000
+
+// calibrationSink keeps the compiler from dropping calibrate's loop.
+var calibrationSink int
+
+// calibrate times a fixed in-process loop, the yardstick --bench-baseline
+// divides every timing by.
+func calibrate() time.Duration {
+	start := time.Now()
+	x := 0
+	for i := 0; i < calibrationLoops; i++ {
+		x = (x*31 + i) % 1000003
+	}
+	calibrationSink = x
+	return time.Since(start)
+}
+
+// printBaseline writes each timing as a multiple of the calibration loop,
+// in language order. The ratios are comparable across machines in a way the
+// raw timings aren't.
+func printBaseline(w io.Writer, timings map[string]int64, baseline int64) {
+	langs := make([]string, 0, len(timings))
+	for lang := range timings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	fmt.Fprintf(w, "Relative to the baseline (%s):\n", time.Duration(baseline))
+	for _, lang := range langs {
+		fmt.Fprintf(w, "  %s: %.2fx\n", lang, float64(timings[lang])/float64(baseline))
+	}
+}
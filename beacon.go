@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// beaconTimeout bounds the whole --beacon request.
+const beaconTimeout = 10 * time.Second
+
+// maxBeaconBody caps how much of a beacon response is read. Real ones are
+// a few KiB at most.
+const maxBeaconBody = 1 << 16
+
+// beaconResponse covers the two beacon formats --beacon understands: a
+// drand round, with its randomness at the top level, and a NIST beacon 2.0
+// pulse, with its outputValue under pulse.
+type beaconResponse struct {
+	Randomness string `json:"randomness"`
+	Pulse      struct {
+		OutputValue string `json:"outputValue"`
+	} `json:"pulse"`
+}
+
+// fetchBeacon GETs url and returns the hex-decoded value of the drand round
+// or NIST pulse it answers with.
+func fetchBeacon(url string) ([]byte, error) {
+	client := &http.Client{Timeout: beaconTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBeaconBody))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	var r beaconResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("%s: not a beacon response: %w", url, err)
+	}
+	value := r.Randomness
+	if value == "" {
+		value = r.Pulse.OutputValue
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%s: no drand randomness or NIST outputValue in the response", url)
+	}
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: beacon value isn't hex: %w", url, err)
+	}
+	if len(b) == 0 {
+		return nil, errors.New(url + ": empty beacon value")
+	}
+	return b, nil
+}
+
+// beaconMix is the --beacon value to mix into the hash. With
+// --beacon-on-error warn a failed fetch is a warning and the seed comes
+// from the local measurements alone.
+func beaconMix(cfg config) ([]byte, error) {
+	b, err := fetchBeacon(cfg.beacon)
+	if err == nil {
+		if cfg.verbosity == VerbosityHeavy {
+			fmt.Fprintf(diag, "[DEBUG] Beacon value: %x\n", b)
+		}
+		return b, nil
+	}
+	if cfg.beaconOnError == "warn" {
+		warnf("--beacon: %v; continuing without it", err)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("--beacon: %w", err)
+}
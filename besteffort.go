@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keepPartial is what a failed run comes to under --best-effort: samples,
+// the languages that did finish, are kept as long as --min-langs of them
+// did, and err becomes a warning on stderr. Otherwise err stands. The
+// caller marks a kept result degraded.
+func keepPartial(samples map[string]sample, err error, cfg config) (map[string]sample, error) {
+	if !cfg.bestEffort || len(samples) == 0 {
+		return nil, err
+	}
+	if len(samples) < cfg.minLangs {
+		return nil, fmt.Errorf("%w\nonly %d languages finished (--min-langs is %d)", err, len(samples), cfg.minLangs)
+	}
+	langs := make([]string, 0, len(samples))
+	for lang := range samples {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	warnf("--best-effort: deriving a degraded seed from %s after:\n%v", strings.Join(langs, ", "), err)
+	return samples, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// jsonBundle is the --bundle document: the --json result, always with the
+// --freeze environment, plus the seed in every --seed-format, the flags
+// the run was given and when it finished.
+type jsonBundle struct {
+	jsonResult
+	SeedFormats map[string]string `json:"seedFormats"`
+	Flags       map[string]string `json:"flags"`
+	CreatedAt   string            `json:"createdAt"`
+}
+
+// writeBundle writes res to cfg.bundle as a jsonBundle. Only flags set on
+// the command line are listed, since version pins the defaults, and --key
+// is recorded as set without its value.
+func writeBundle(cfg config, res *Result) error {
+	out := jsonBundle{
+		jsonResult:  newJSONResult(cfg, res),
+		SeedFormats: make(map[string]string, len(seedFormats)),
+		Flags:       make(map[string]string),
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	out.Environment = freezeEnvironment(cfg)
+	for _, f := range seedFormats {
+		if f == "uuid" && cfg.seedBits < 128 {
+			continue
+		}
+		c := cfg
+		c.seedFormat = f
+		out.SeedFormats[f] = formatSeed(res.Seed, c)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		out.Flags[f.Name] = f.Value.String()
+		if f.Name == "key" {
+			out.Flags[f.Name] = "(redacted)"
+		}
+	})
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cfg.bundle, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("--bundle: %w", err)
+	}
+	return nil
+}
+
+// saveBundle writes --bundle, if set, exiting on failure.
+func saveBundle(cfg config, res *Result) {
+	if cfg.bundle == "" {
+		return
+	}
+	if err := writeBundle(cfg, res); err != nil {
+		fmt.Fprintln(errOut, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// checkCompile is --check-compile: it builds every compiled task cfg
+// selects, manifest languages with a compile command included, in a
+// throwaway directory and prints whether each one compiled, with the
+// compiler's output under each failure. Nothing is timed or kept. It
+// returns an error if anything failed to build.
+func checkCompile(w io.Writer, cfg config) error {
+	tmpdir, err := os.MkdirTemp(cfg.tmpdir, "prandom_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Every language gets tried, whatever --fail-fast says.
+	cfg.failFast = false
+	_, buildErr := compileAll(tmpdir, cfg)
+	failed := make(map[string]error)
+	for _, err := range flattenErrors(buildErr) {
+		var ce *ErrCompile
+		if !errors.As(err, &ce) {
+			return buildErr
+		}
+		failed[ce.Lang] = ce.Err
+	}
+
+	langs := compiledLangs(cfg)
+	for _, l := range cfg.manifest {
+		if len(l.Compile) > 0 {
+			langs = append(langs, l.Name)
+		}
+	}
+	width := 0
+	for _, lang := range langs {
+		width = max(width, len(lang))
+	}
+	for _, lang := range langs {
+		err := failed[lang]
+		if err == nil {
+			fmt.Fprintf(w, "  %-*s  ok       %s\n", width, lang, compileTimes.duration(lang).Round(time.Millisecond))
+			continue
+		}
+		first, output, _ := strings.Cut(err.Error(), "\n")
+		fmt.Fprintf(w, "  %-*s  FAILED   %s\n", width, lang, first)
+		for _, line := range strings.Split(output, "\n") {
+			if line != "" {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	// An ErrCompile, so the exit status is the usual one for a failed build.
+	var names []string
+	for _, lang := range langs {
+		if failed[lang] != nil {
+			names = append(names, lang)
+		}
+	}
+	return &ErrCompile{Lang: strings.Join(names, ", "), Err: errors.New("failed, see above")}
+}
+
+// flattenErrors lists the errors joined into err, however deeply, and none
+// for a nil err.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var out []error
+	for _, e := range joined.Unwrap() {
+		out = append(out, flattenErrors(e)...)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// ANSI SGR sequences used for --color.
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// errOut is where main reports failures. setupColor swaps it for a
+// coloring writer when stderr gets color.
+var errOut io.Writer = os.Stderr
+
+// langColor is set when diag gets color, and makes paintLang color names.
+var langColor bool
+
+// setupColor applies --color to diag and errOut. auto colors a stream only
+// when it's a terminal and NO_COLOR isn't set, per no-color.org.
+func setupColor(mode string) {
+	want := func(w io.Writer) bool {
+		switch mode {
+		case "always":
+			return true
+		case "never":
+			return false
+		}
+		return os.Getenv("NO_COLOR") == "" && isTerminal(w)
+	}
+	if want(diag) {
+		diag = colorWriter{w: diag}
+		langColor = true
+	}
+	if want(os.Stderr) {
+		errOut = colorWriter{w: os.Stderr, whole: ansiRed}
+	}
+}
+
+// paintLang colors a language name for diag output.
+func paintLang(lang string) string {
+	if !langColor {
+		return lang
+	}
+	return ansiGreen + lang + ansiReset
+}
+
+// colorWriter colors what's written through it. With whole set, each write
+// is painted that color entirely; otherwise only the [DEBUG] and warning:
+// markers are, so the message itself stays readable.
+type colorWriter struct {
+	w     io.Writer
+	whole string
+}
+
+var colorPrefixes = []struct {
+	plain, painted []byte
+}{
+	{[]byte("[DEBUG]"), []byte(ansiCyan + "[DEBUG]" + ansiReset)},
+	{[]byte("warning:"), []byte(ansiYellow + "warning:" + ansiReset)},
+}
+
+func (c colorWriter) Write(p []byte) (int, error) {
+	var out []byte
+	if c.whole != "" {
+		body, nl := bytes.CutSuffix(p, []byte("\n"))
+		out = append(append([]byte(c.whole), body...), ansiReset...)
+		if nl {
+			out = append(out, '\n')
+		}
+	} else {
+		out = p
+		for _, cp := range colorPrefixes {
+			out = bytes.ReplaceAll(out, cp.plain, cp.painted)
+		}
+	}
+	if _, err := c.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// commitNonceLen is how many random bytes --commit appends to the seed
+// before hashing it.
+const commitNonceLen = 32
+
+// seedCommitment is the --commit file: the commitment and nonce that get
+// published, and the seed they commit to, which doesn't until --reveal.
+type seedCommitment struct {
+	Commitment string `json:"commitment"`
+	Nonce      string `json:"nonce"`
+	Bits       int    `json:"bits"`
+	Seed       string `json:"seed"`
+}
+
+// commitmentOf is SHA-256 over the seed as ceil(bits/8) big-endian bytes,
+// the same bytes --output writes, followed by the nonce.
+func commitmentOf(seed *big.Int, bits int, nonce []byte) []byte {
+	h := sha256.New()
+	h.Write(seed.FillBytes(make([]byte, (bits+7)/8)))
+	h.Write(nonce)
+	return h.Sum(nil)
+}
+
+// writeCommitment commits to res.Seed with a fresh nonce, saves the lot to
+// cfg.commit and prints the commitment and nonce to w. The file is created
+// private and never overwritten, so an earlier commitment's seed can't be
+// lost to a second run.
+func writeCommitment(w io.Writer, cfg config, res *Result) error {
+	nonce := make([]byte, commitNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("--commit: %w", err)
+	}
+	c := seedCommitment{
+		Commitment: hex.EncodeToString(commitmentOf(res.Seed, cfg.seedBits, nonce)),
+		Nonce:      hex.EncodeToString(nonce),
+		Bits:       cfg.seedBits,
+		Seed:       hex.EncodeToString(res.Seed.FillBytes(make([]byte, (cfg.seedBits+7)/8))),
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cfg.commit, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("--commit: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("--commit: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("--commit: %w", err)
+	}
+	fmt.Fprintf(w, "Commitment (sha256): %s\n", c.Commitment)
+	fmt.Fprintf(w, "Nonce: %s\n", c.Nonce)
+	return nil
+}
+
+// reveal reads a --commit file, checks that its seed and nonce hash to its
+// commitment, and to cfg.commitment as well if that's set, then prints the
+// seed to w in --seed-format.
+func reveal(w io.Writer, cfg config) error {
+	b, err := os.ReadFile(cfg.reveal)
+	if err != nil {
+		return fmt.Errorf("--reveal: %w", err)
+	}
+	var c seedCommitment
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("--reveal: %s isn't a --commit file: %w", cfg.reveal, err)
+	}
+	raw, err := hex.DecodeString(c.Seed)
+	if err != nil {
+		return fmt.Errorf("--reveal: seed isn't hex: %w", err)
+	}
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil {
+		return fmt.Errorf("--reveal: nonce isn't hex: %w", err)
+	}
+	recorded, err := hex.DecodeString(c.Commitment)
+	if err != nil {
+		return fmt.Errorf("--reveal: commitment isn't hex: %w", err)
+	}
+	seed := new(big.Int).SetBytes(raw)
+	if c.Bits < 1 || len(raw) != (c.Bits+7)/8 || seed.BitLen() > c.Bits {
+		return fmt.Errorf("--reveal: seed doesn't fit the recorded %d bits", c.Bits)
+	}
+
+	sum := commitmentOf(seed, c.Bits, nonce)
+	if !bytes.Equal(sum, recorded) {
+		return errors.New("--reveal: seed and nonce don't match the commitment in the file")
+	}
+	if cfg.commitment != "" {
+		published, err := hex.DecodeString(cfg.commitment)
+		if err != nil || !bytes.Equal(sum, published) {
+			return fmt.Errorf("--reveal: seed and nonce don't match --commitment %s", cfg.commitment)
+		}
+	}
+
+	fmt.Fprintf(diag, "Commitment %x verified\n", sum)
+	if cfg.seedFormat == "uuid" && c.Bits < 128 {
+		return fmt.Errorf("--reveal: a %d-bit seed is too short for --seed-format uuid", c.Bits)
+	}
+	cfg.seedBits = c.Bits
+	fmt.Fprintf(w, "Seed revealed (%d-bit): %s\n", c.Bits, formatSeed(seed, cfg))
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compileOnly builds every compiled task cfg selects, manifest languages
+// with a compile command included, and copies the executables into
+// cfg.compileOnly. Nothing is timed or hashed.
+func compileOnly(cfg config) error {
+	if err := os.MkdirAll(cfg.compileOnly, 0755); err != nil {
+		return err
+	}
+	tmpdir, err := os.MkdirTemp(cfg.tmpdir, "prandom_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	exes, err := compileAll(tmpdir, cfg)
+	if err != nil && cfg.failFast {
+		return err
+	}
+	for lang, exe := range exes {
+		dst := filepath.Join(cfg.compileOnly, filepath.Base(exe))
+		if err := copyExecutable(exe, dst, cfg); err != nil {
+			return fmt.Errorf("copying %s: %w", lang, err)
+		}
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "Wrote %s\n", dst)
+		}
+	}
+	return err
+}
+
+// compileAll builds every compiled task cfg selects in tmpdir, manifest
+// languages with a compile command included, and returns each executable's
+// path. With --fail-fast=false the builds that worked come back next to the
+// joined errors of those that didn't. Nothing runs after the builds, so
+// they need no task group: each one is waited for before this returns.
+func compileAll(tmpdir string, cfg config) (map[string]string, error) {
+	ctx := context.Background()
+	exes, compileErr := writeAndCompileExtra(ctx, tmpdir, cfg, nil)
+	if compileErr != nil && cfg.failFast {
+		return nil, compileErr
+	}
+	var manifestErr error
+	if len(cfg.manifest) > 0 {
+		var procs map[string][]string
+		procs, manifestErr = prepareManifest(ctx, tmpdir, cfg)
+		if manifestErr != nil && cfg.failFast {
+			return nil, manifestErr
+		}
+		for _, l := range cfg.manifest {
+			if _, ok := procs[l.Name]; ok && len(l.Compile) > 0 {
+				exes[l.Name] = filepath.Join(tmpdir, "task_"+l.Name+".exe")
+			}
+		}
+	}
+	return exes, errors.Join(compileErr, manifestErr)
+}
+
+// copyExecutable copies src to dst, replacing dst if it exists, with the
+// binary mode --file-mode asks for.
+func copyExecutable(src, dst string, cfg config) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	mode := os.FileMode(0755)
+	if cfg.fileMode != 0 {
+		mode = binaryMode(cfg.fileMode)
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil || cfg.fileMode == 0 {
+		return err
+	}
+	// OpenFile only applies mode when it creates dst.
+	return os.Chmod(dst, mode)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// compileClock keeps how long each language's last successful build took,
+// for --include-compile-time. With --retries only the attempt that worked
+// counts, and a later build of the same language replaces it.
+type compileClock struct {
+	mu sync.Mutex
+	ns map[string]int64
+}
+
+// compileTimes is filled by every compile helper as builds finish, the way
+// prog counts them.
+var compileTimes = &compileClock{}
+
+// time runs build and, if it succeeds, records its wall-clock duration for
+// lang.
+func (c *compileClock) time(lang string, build func() error) error {
+	start := time.Now()
+	if err := build(); err != nil {
+		return err
+	}
+	d, _ := clampDuration(time.Since(start))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ns == nil {
+		c.ns = make(map[string]int64)
+	}
+	c.ns[lang] = d
+	return nil
+}
+
+// duration is how long lang's last successful build took.
+func (c *compileClock) duration(lang string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.ns[lang])
+}
+
+// addCompileTimes records, for --include-compile-time, how long every
+// compiled task in samples took to build. Tasks nothing was built for keep
+// 0, like addBinSizes.
+func addCompileTimes(samples map[string]sample) {
+	compileTimes.mu.Lock()
+	defer compileTimes.mu.Unlock()
+	for lang, smp := range samples {
+		if ns, ok := compileTimes.ns[lang]; ok {
+			smp.compileNs = ns
+			samples[lang] = smp
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// containerRuntimes are the runtimes --container looks for, in order.
+var containerRuntimes = []string{"docker", "podman"}
+
+// findContainerRuntime resolves the first of containerRuntimes on the PATH.
+func findContainerRuntime() (string, error) {
+	for _, name := range containerRuntimes {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("--container needs " + strings.Join(containerRuntimes, " or ") + " on the PATH")
+}
+
+// containerTasks rewrites every command in procMap to run in a throwaway
+// cfg.container container with tmpdir bind-mounted at the same path, so
+// task files and executables keep their names. Host tool paths mean nothing
+// inside the image, so a command that isn't in tmpdir is run by its base
+// name and found on the image's PATH instead.
+func containerTasks(procMap map[string][]string, tmpdir string, cfg config) map[string][]string {
+	prefix := []string{cfg.containerRuntime, "run", "--rm", "-i", "-v", tmpdir + ":" + tmpdir, "-w", tmpdir}
+	if cfg.taskThreads > 0 {
+		for _, v := range threadEnvVars {
+			prefix = append(prefix, "-e", fmt.Sprintf("%s=%d", v, cfg.taskThreads))
+		}
+	}
+	prefix = append(prefix, cfg.container)
+
+	out := make(map[string][]string, len(procMap))
+	for lang, args := range procMap {
+		bin := args[0]
+		if !strings.HasPrefix(bin, tmpdir+string(filepath.Separator)) {
+			bin = filepath.Base(bin)
+		}
+		cmd := append(append(append([]string(nil), prefix...), bin), args[1:]...)
+		out[lang] = cmd
+		if cfg.verbosity == VerbosityHeavy {
+			fmt.Fprintf(diag, "[DEBUG] %s runs in %s: %v\n", lang, cfg.container, cmd)
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// cppCompilerTools maps each --cpp-compilers name to the driver its cpp
+// task is built with.
+var cppCompilerTools = map[string]string{"gcc": "g++", "clang": "clang++"}
+
+// cppVariant returns the --cpp-compilers name in a task name like
+// cpp-clang, or "" if lang isn't one.
+func cppVariant(lang string) string {
+	if c, ok := strings.CutPrefix(lang, "cpp-"); ok && cppCompilerTools[c] != "" {
+		return c
+	}
+	return ""
+}
+
+// baseLang is the language whose snippet lang runs: cpp for every
+// --cpp-compilers variant and lang itself for everything else.
+func baseLang(lang string) string {
+	if cppVariant(lang) != "" {
+		return "cpp"
+	}
+	return lang
+}
+
+// langProbe is langProbes[lang], plus the driver of a --cpp-compilers
+// variant.
+func langProbe(lang string) (toolProbe, bool) {
+	if c := cppVariant(lang); c != "" {
+		return toolProbe{cppCompilerTools[c], []string{"--version"}}, true
+	}
+	p, ok := langProbes[lang]
+	return p, ok
+}
+
+// cppCompiler is compileCpp for the --cpp-compilers variant lang, building
+// task_<lang>.exe with that variant's driver instead of g++.
+func cppCompiler(lang string) func(context.Context, string, config) (string, error) {
+	tool := cppCompilerTools[cppVariant(lang)]
+	return func(ctx context.Context, path string, cfg config) (string, error) {
+		return buildCpp(ctx, tool, filepath.Join(filepath.Dir(path), "task_"+lang+".exe"), path, cfg)
+	}
+}
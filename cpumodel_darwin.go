@@ -0,0 +1,12 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// cpuModel returns the machdep.cpu.brand_string sysctl.
+func cpuModel() string {
+	s, err := unix.Sysctl("machdep.cpu.brand_string")
+	if err != nil {
+		return ""
+	}
+	return s
+}
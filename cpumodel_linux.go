@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// cpuModel returns the first "model name" in /proc/cpuinfo, or "" if there
+// isn't one (most ARM kernels leave it out).
+func cpuModel() string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), ":")
+		if ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(val)
+		}
+	}
+	return ""
+}
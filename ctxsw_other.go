@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+const ctxSwitchesSupported = false
+
+func ctxSwitches(ps *os.ProcessState) int64 {
+	return 0
+}
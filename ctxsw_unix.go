@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const ctxSwitchesSupported = true
+
+// ctxSwitches is how many times a finished process was switched out, the
+// voluntary and involuntary context switches from its rusage added up.
+func ctxSwitches(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	return int64(ru.Nvcsw) + int64(ru.Nivcsw)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "encoding/binary"
+
+// debiasBits is how many low-order bits of each timing --debias keeps. The
+// higher bits mostly say how long the workload takes, not how much it
+// wobbled.
+const debiasBits = 16
+
+// debiasTimings runs a von Neumann extractor over the low debiasBits of
+// every timing, taken in the given language order, lowest bit first. Each
+// pair of bits turns 01 into 0 and 10 into 1 and drops 00 and 11, which
+// removes any fixed bias from independent bits at the cost of three quarters
+// of them on average. The result is the number of bits kept as a 4-byte
+// big-endian count, followed by those bits packed MSB-first, so trailing
+// padding can't collide with a real 0.
+func debiasTimings(langs []string, timings map[string]int64) []byte {
+	var out []byte
+	var n uint32
+	for _, lang := range langs {
+		v := uint64(timings[lang])
+		for i := 0; i < debiasBits; i += 2 {
+			a, b := v>>i&1, v>>(i+1)&1
+			if a == b {
+				continue
+			}
+			if n%8 == 0 {
+				out = append(out, 0)
+			}
+			if a == 1 {
+				out[len(out)-1] |= 0x80 >> (n % 8)
+			}
+			n++
+		}
+	}
+	return append(binary.BigEndian.AppendUint32(nil, n), out...)
+}
+
+// debiasedEmpty reports whether debiasTimings would keep no bits at all,
+// which would leave the hash with nothing but the salt and mix. Order
+// doesn't change the count, so any will do.
+func debiasedEmpty(timings map[string]int64) bool {
+	langs := make([]string, 0, len(timings))
+	for lang := range timings {
+		langs = append(langs, lang)
+	}
+	return binary.BigEndian.Uint32(debiasTimings(langs, timings)) == 0
+}
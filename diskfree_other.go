@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
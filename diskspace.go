@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// lowDiskSpace is the free space below which ptrsg warns before compiling.
+// The compiled tasks themselves are a few MB, but rustc and go build want
+// room for intermediates too.
+const lowDiskSpace = 200 << 20
+
+// checkDiskSpace warns if the filesystem holding dir looks too full to
+// compile the tasks. It stays quiet where free space can't be measured.
+func checkDiskSpace(dir string) {
+	free, err := diskFree(dir)
+	if err != nil {
+		return
+	}
+	if free < lowDiskSpace {
+		warnf("only %d MB free in %s, compiling may fail; try --tmpdir somewhere with more room", free>>20, dir)
+	}
+}
+
+// writeTaskError wraps a failed write of a task's source with which language
+// and file it was, plus a --tmpdir hint when the disk is full.
+func writeTaskError(lang, path string, err error) error {
+	err = fmt.Errorf("writing %s task to %s: %w", lang, path, err)
+	if errors.Is(err, syscall.ENOSPC) {
+		err = fmt.Errorf("%w\nhint: the disk is full; try --tmpdir pointing at a filesystem with more room", err)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"sort"
+)
+
+// jsonEnvironment is the --freeze snapshot of what a run was measured on:
+// enough to tell whether two results' timings are comparable.
+type jsonEnvironment struct {
+	OS          string     `json:"os"`
+	Arch        string     `json:"arch"`
+	CPU         string     `json:"cpu,omitempty"`
+	NumCPU      int        `json:"numCpu"`
+	GoRuntime   string     `json:"goRuntime"`
+	Workload    string     `json:"workload"`
+	Iterations  int        `json:"iterations"`
+	GoCompiler  string     `json:"goCompiler"`
+	CflagsCpp   []string   `json:"cflagsCpp,omitempty"`
+	CflagsRust  []string   `json:"cflagsRust,omitempty"`
+	Gcflags     string     `json:"gcflags,omitempty"`
+	TaskThreads int        `json:"taskThreads,omitempty"`
+	Tools       []jsonTool `json:"tools"`
+}
+
+// toolStatuses is what preflight found, kept for --freeze.
+var toolStatuses map[string]toolStatus
+
+// freezeEnvironment gathers the --freeze snapshot. The tool versions are
+// the ones preflight already probed, so nothing is run twice.
+func freezeEnvironment(cfg config) *jsonEnvironment {
+	env := &jsonEnvironment{
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		CPU:         cpuModel(),
+		NumCPU:      runtime.NumCPU(),
+		GoRuntime:   runtime.Version(),
+		Workload:    cfg.workload,
+		Iterations:  max(cfg.iterations, 1),
+		GoCompiler:  cfg.goCompiler,
+		CflagsCpp:   cfg.cppFlags,
+		CflagsRust:  cfg.rustFlags,
+		Gcflags:     cfg.goGCFlags,
+		TaskThreads: cfg.taskThreads,
+		Tools:       []jsonTool{},
+	}
+	names := make([]string, 0, len(toolStatuses))
+	for name := range toolStatuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := toolStatuses[name]
+		env.Tools = append(env.Tools, jsonTool{Name: name, Found: t.Found, Version: t.Version, Family: t.Family, Path: t.Path, Error: t.Err})
+	}
+	return env
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Exit codes main uses for each failure mode. Anything not covered below,
+// including bad flags, exits 1.
+const (
+	exitPreflight = 3
+	exitCompile   = 4
+	exitRun       = 5
+)
+
+// ErrPreflight means one or more required tools couldn't be found or run.
+type ErrPreflight struct {
+	Missing []string
+}
+
+func (e *ErrPreflight) Error() string {
+	return fmt.Sprintf("Preflight check failed: %s missing!", strings.Join(e.Missing, ", "))
+}
+
+// ErrToolVersion means a tool is older than --require allows. Have is
+// empty when its version couldn't be read at all.
+type ErrToolVersion struct {
+	Tool string
+	Have string
+	Want string
+}
+
+func (e *ErrToolVersion) Error() string {
+	if e.Have == "" {
+		return fmt.Sprintf("--require %s>=%s: couldn't read %s's version", e.Tool, e.Want, e.Tool)
+	}
+	return fmt.Sprintf("--require %s>=%s: found %s %s", e.Tool, e.Want, e.Tool, e.Have)
+}
+
+// ErrCompile means a compiled task failed to build.
+type ErrCompile struct {
+	Lang string
+	Err  error
+}
+
+func (e *ErrCompile) Error() string {
+	return fmt.Sprintf("compiling %s: %v", e.Lang, e.Err)
+}
+
+func (e *ErrCompile) Unwrap() error { return e.Err }
+
+// ErrRun means a task failed while being timed. ExitCode is the exit status
+// of the last attempt, or -1 if the process never got that far.
+type ErrRun struct {
+	Lang     string
+	ExitCode int
+	Err      error
+}
+
+func (e *ErrRun) Error() string {
+	return fmt.Sprintf("running %s: %v", e.Lang, e.Err)
+}
+
+func (e *ErrRun) Unwrap() error { return e.Err }
+
+// exitCode picks the process exit status for err.
+func exitCode(err error) int {
+	var pre *ErrPreflight
+	var ver *ErrToolVersion
+	var comp *ErrCompile
+	var run *ErrRun
+	switch {
+	case errors.As(err, &pre), errors.As(err, &ver):
+		return exitPreflight
+	case errors.As(err, &comp):
+		return exitCompile
+	case errors.As(err, &run):
+		return exitRun
+	}
+	return 1
+}
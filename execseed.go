@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runExec is --exec: it runs cfg.exec with the seed substituted into each
+// argument and returns the command's exit code for ptrsg to exit with.
+// {seed} is the seed in --seed-format, {hex} the raw bytes as hex and {raw}
+// the path of a private temp file holding them, removed once the command
+// exits. With --env-var the seed is also set in the command's environment,
+// in --seed-format. Only the first seed is passed on.
+func runExec(cfg config, res *Result) (int, error) {
+	var rawPath string
+	for _, arg := range cfg.exec {
+		if strings.Contains(arg, "{raw}") {
+			f, err := os.CreateTemp(cfg.tmpdir, "ptrsg_seed_")
+			if err != nil {
+				return 0, err
+			}
+			rawPath = f.Name()
+			defer os.Remove(rawPath)
+			_, err = f.Write(res.Raw)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return 0, fmt.Errorf("--exec: writing %s: %w", rawPath, err)
+			}
+			break
+		}
+	}
+
+	r := strings.NewReplacer("{seed}", formatSeed(res.Seed, cfg), "{hex}", hex.EncodeToString(res.Raw), "{raw}", rawPath)
+	args := make([]string, len(cfg.exec))
+	for i, arg := range cfg.exec {
+		args[i] = r.Replace(arg)
+	}
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] --exec: %v\n", args)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if cfg.envVar != "" {
+		cmd.Env = append(os.Environ(), cfg.envVar+"="+formatSeed(res.Seed, cfg))
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("--exec: %w", err)
+	}
+	return 0, nil
+}
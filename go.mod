@@ -4,4 +4,4 @@ go 1.24.4
 
 require golang.org/x/crypto v0.39.0
 
-require golang.org/x/sys v0.33.0 // indirect
+require golang.org/x/sys v0.33.0
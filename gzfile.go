@@ -0,0 +1,80 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// isGzip reports whether path asks for gzip compression, by its .gz
+// extension.
+func isGzip(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// openAppend opens path for appending, creating it with perm if needed. A
+// .gz path gets each batch of writes as its own gzip member: concatenated
+// members are a valid gzip stream, so appending never has to rewrite what's
+// already there.
+func openAppend(path string, perm os.FileMode) (io.WriteCloser, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	if !isGzip(path) {
+		return f, nil
+	}
+	return &gzipWriter{gzip.NewWriter(f), f}, nil
+}
+
+// openRead opens path for reading, decompressing every member of a .gz
+// path in turn.
+func openRead(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isGzip(path) {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err == io.EOF {
+		// An empty file has no members yet, which reads as nothing.
+		return f, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &gzipReader{zr, f}, nil
+}
+
+// gzipWriter and gzipReader pair a gzip stream with the file under it, so
+// closing one finishes the gzip side before closing the file.
+type gzipWriter struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (g *gzipWriter) Close() error {
+	err := g.Writer.Close()
+	if cerr := g.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type gzipReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipReader) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
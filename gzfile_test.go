@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	for _, name := range []string{"pool", "pool.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		var want []byte
+		// Three appends, so a .gz file ends up with three members.
+		for _, rec := range []string{"first\n", "second\n", "third\n"} {
+			w, err := openAppend(path, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, rec); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, rec...)
+		}
+
+		onDisk, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := !bytes.Equal(onDisk, want); compressed != isGzip(path) {
+			t.Errorf("%s: compressed on disk = %v, want %v", name, compressed, isGzip(path))
+		}
+
+		r, err := openRead(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: read back %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGzipPoolTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.gz")
+	if tail, err := readPoolTail(path); err != nil || tail != nil {
+		t.Fatalf("missing pool: got %x, %v; want nothing", tail, err)
+	}
+	var all []byte
+	for i := range 3 {
+		seed := bytes.Repeat([]byte{byte(i + 1)}, 40)
+		if err := appendPool(path, seed); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, seed...)
+	}
+	tail, err := readPoolTail(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := all[len(all)-poolTailSize:]; !bytes.Equal(tail, want) {
+		t.Errorf("tail = %x, want %x", tail, want)
+	}
+}
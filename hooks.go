@@ -0,0 +1,47 @@
+package main
+
+import "time"
+
+// Hooks lets code embedding Generate watch a run as it goes, say to drive
+// its own progress display or record metrics, instead of parsing the
+// output. Every callback is optional; unset ones do nothing. Probes,
+// compiles and runs happen concurrently, so their callbacks can be called
+// from several goroutines at once and must not block for long.
+type Hooks struct {
+	// OnToolProbed is called once preflight has looked for each tool, with
+	// the version it reported if it was found.
+	OnToolProbed func(name string, ok bool, version string)
+	// OnCompiled is called after each compiled task builds. dur is 0 for a
+	// manifest language that reused an identical build.
+	OnCompiled func(lang string, dur time.Duration)
+	// OnRan is called after each task has been timed, or has failed to be,
+	// retries included.
+	OnRan func(lang string, dur time.Duration, err error)
+	// OnHashed is called with the full blake2b digest the first seed is cut
+	// from, before any seeds are reported.
+	OnHashed func(digest []byte)
+}
+
+func (h Hooks) toolProbed(name string, ok bool, version string) {
+	if h.OnToolProbed != nil {
+		h.OnToolProbed(name, ok, version)
+	}
+}
+
+func (h Hooks) compiled(lang string, dur time.Duration) {
+	if h.OnCompiled != nil {
+		h.OnCompiled(lang, dur)
+	}
+}
+
+func (h Hooks) ran(lang string, smp sample, err error) {
+	if h.OnRan != nil {
+		h.OnRan(lang, time.Duration(smp.ns), err)
+	}
+}
+
+func (h Hooks) hashed(digest []byte) {
+	if h.OnHashed != nil {
+		h.OnHashed(digest)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooksToolProbed(t *testing.T) {
+	dir := t.TempDir()
+	lua := filepath.Join(dir, "lua")
+	if err := os.WriteFile(lua, []byte("#!/bin/sh\necho 'Lua 5.4.6  Copyright (C) 1994-2023 Lua.org, PUC-Rio'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	type probe struct {
+		ok      bool
+		version string
+	}
+	var mu sync.Mutex
+	got := make(map[string]probe)
+	cfg := testConfig()
+	cfg.chaos = "low"
+	cfg.hooks.OnToolProbed = func(name string, ok bool, version string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[name] = probe{ok, version}
+	}
+	statuses := preflightLangCheck(cfg)
+
+	if len(got) != len(statuses) {
+		t.Errorf("OnToolProbed called for %v, want every tool in %v", got, statuses)
+	}
+	for name, st := range statuses {
+		want := probe{st.Found, st.Version}
+		if !st.Found {
+			want.version = ""
+		}
+		if got[name] != want {
+			t.Errorf("%s: OnToolProbed got %+v, want %+v", name, got[name], want)
+		}
+	}
+	if got["lua"] != (probe{true, "5.4.6"}) {
+		t.Errorf("lua: OnToolProbed got %+v, want found at 5.4.6", got["lua"])
+	}
+}
+
+func TestHooksCompiled(t *testing.T) {
+	tmpdir := t.TempDir()
+	var mu sync.Mutex
+	got := make(map[string]time.Duration)
+	cfg := testConfig()
+	cfg.failFast = true
+	cp := []string{"/bin/sh", "-c", "cp {src} {exe}"}
+	cfg.manifest = []manifestLang{
+		{Name: "first", Source: "x", Compile: cp, Run: []string{"{exe}"}},
+		{Name: "second", Source: "x", Compile: cp, Run: []string{"{exe}"}},
+	}
+	cfg.hooks.OnCompiled = func(lang string, dur time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[lang] = dur
+	}
+	if _, err := prepareManifest(context.Background(), tmpdir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := got["first"]; !ok || d <= 0 {
+		t.Errorf("first: OnCompiled got %v, %v; want its build time", d, ok)
+	}
+	// second has the same build as first, so it's linked rather than built.
+	if d, ok := got["second"]; !ok || d != 0 {
+		t.Errorf("second: OnCompiled got %v, %v; want 0 for a reused build", d, ok)
+	}
+}
+
+func TestHooksRan(t *testing.T) {
+	type run struct {
+		dur time.Duration
+		err error
+	}
+	var mu sync.Mutex
+	got := make(map[string]run)
+	cfg := testConfig()
+	cfg.iterations = 1
+	cfg.hooks.OnRan = func(lang string, dur time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got[lang] = run{dur, err}
+	}
+	_, err := runTasks(context.Background(), map[string][]string{
+		"pass": {"/bin/sh", "-c", "exit 0"},
+		"fail": {"/bin/sh", "-c", "exit 3"},
+	}, cfg)
+	if err == nil {
+		t.Fatal("a task exiting 3 didn't fail the run")
+	}
+	if r := got["pass"]; r.err != nil || r.dur <= 0 {
+		t.Errorf("pass: OnRan got %+v, want its timing and no error", r)
+	}
+	var exit interface{ ExitCode() int }
+	if r := got["fail"]; !errors.As(r.err, &exit) || exit.ExitCode() != 3 {
+		t.Errorf("fail: OnRan got %+v, want its exit status 3", r)
+	}
+}
+
+func TestHooksHashed(t *testing.T) {
+	var got []byte
+	calls := 0
+	cfg := testConfig()
+	cfg.hooks.OnHashed = func(digest []byte) {
+		calls++
+		got = bytes.Clone(digest)
+	}
+	res, err := resultFromSamples(map[string]sample{"go": {ns: 5_000_000}, "lua": {ns: 7_000_000}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || !bytes.Equal(got, res.Hash) {
+		t.Errorf("OnHashed called %d times with %x, want once with %x", calls, got, res.Hash)
+	}
+}
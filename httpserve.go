@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// seedService is what --http serves from a --stream run. measure times the
+// already-built tasks once and derives a seed, one call at a time so the
+// stream rounds and /seed requests never measure on top of each other.
+type seedService struct {
+	cfg config
+	mu  sync.Mutex
+	run func() (*Result, error)
+
+	// lastOK is when the last measurement succeeded, in Unix ns, 0 before
+	// the first, and took how long that one ran, so /healthz knows a slow
+	// round from a stuck one.
+	lastOK atomic.Int64
+	took   atomic.Int64
+
+	// For /metrics: how many measurements succeeded and failed, and the
+	// timings from the last one that worked.
+	okRuns      atomic.Int64
+	failedRuns  atomic.Int64
+	timingsMu   sync.Mutex
+	lastTimings map[string]int64
+}
+
+func (s *seedService) measure() (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := time.Now()
+	res, err := s.run()
+	if err != nil {
+		s.failedRuns.Add(1)
+		return res, err
+	}
+	s.took.Store(int64(time.Since(start)))
+	s.lastOK.Store(time.Now().UnixNano())
+	s.okRuns.Add(1)
+	s.timingsMu.Lock()
+	s.lastTimings = res.Timings
+	s.timingsMu.Unlock()
+	return res, nil
+}
+
+// healthz answers 200 while the last successful measurement is less than
+// two --stream intervals plus its own duration old, and 503 otherwise.
+func (s *seedService) healthz(w http.ResponseWriter, r *http.Request) {
+	last := s.lastOK.Load()
+	if last == 0 {
+		http.Error(w, "no measurement yet", http.StatusServiceUnavailable)
+		return
+	}
+	age := time.Since(time.Unix(0, last))
+	if limit := 2*s.cfg.stream + time.Duration(s.took.Load()); age > limit {
+		http.Error(w, fmt.Sprintf("last measurement %s ago", age.Round(time.Millisecond)), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// seed measures a fresh seed for the request and returns it in
+// --seed-format.
+func (s *seedService) seed(w http.ResponseWriter, r *http.Request) {
+	res, err := s.measure()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, formatSeed(res.Seed, s.cfg))
+}
+
+// metrics writes the --metrics-format prometheus gauges and counters in the
+// Prometheus text format. It never measures, so scraping doesn't add runs.
+func (s *seedService) metrics(w http.ResponseWriter, r *http.Request) {
+	s.timingsMu.Lock()
+	timings := s.lastTimings
+	s.timingsMu.Unlock()
+	langs := make([]string, 0, len(timings))
+	for lang := range timings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP ptrsg_task_duration_seconds How long each language's task took in the last successful measurement.")
+	fmt.Fprintln(w, "# TYPE ptrsg_task_duration_seconds gauge")
+	for _, lang := range langs {
+		fmt.Fprintf(w, "ptrsg_task_duration_seconds{lang=%q} %g\n", lang, float64(timings[lang])/1e9)
+	}
+	fmt.Fprintln(w, "# HELP ptrsg_measurements_total Measurements that produced a seed.")
+	fmt.Fprintln(w, "# TYPE ptrsg_measurements_total counter")
+	fmt.Fprintf(w, "ptrsg_measurements_total %d\n", s.okRuns.Load())
+	fmt.Fprintln(w, "# HELP ptrsg_measurement_failures_total Measurements that failed.")
+	fmt.Fprintln(w, "# TYPE ptrsg_measurement_failures_total counter")
+	fmt.Fprintf(w, "ptrsg_measurement_failures_total %d\n", s.failedRuns.Load())
+	if last := s.lastOK.Load(); last != 0 {
+		fmt.Fprintln(w, "# HELP ptrsg_last_success_timestamp_seconds When the last successful measurement finished.")
+		fmt.Fprintln(w, "# TYPE ptrsg_last_success_timestamp_seconds gauge")
+		fmt.Fprintf(w, "ptrsg_last_success_timestamp_seconds %.3f\n", float64(last)/1e9)
+	}
+}
+
+// serveHTTP starts --http on cfg.http and returns once it's listening, so
+// a bad address fails the run straight away. The server shuts down when ctx
+// is done; the returned function waits for that to finish.
+func serveHTTP(ctx context.Context, s *seedService) (func(), error) {
+	ln, err := net.Listen("tcp", s.cfg.http)
+	if err != nil {
+		return nil, fmt.Errorf("--http: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /seed", s.seed)
+	paths := "/healthz and /seed"
+	if s.cfg.metricsFormat == "prometheus" {
+		mux.HandleFunc("GET /metrics", s.metrics)
+		paths = "/healthz, /seed and /metrics"
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintln(errOut, "--http:", err)
+		}
+	}()
+	// Shutdown lets a /seed in flight finish, which is quick once ctx has
+	// cancelled its tasks.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if s.cfg.verbosity >= VerbosityLite {
+		fmt.Fprintf(diag, "Serving %s on %s\n", paths, ln.Addr())
+	}
+	return func() { <-done }, nil
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+)
+
+// isolateTasks prefixes every command in procMap with taskset so each
+// language gets a CPU to itself, assigned round-robin in language order.
+func isolateTasks(procMap map[string][]string, cfg config) map[string][]string {
+	taskset, err := exec.LookPath("taskset")
+	if err != nil {
+		warnf("--isolate needs taskset, running tasks unpinned")
+		return procMap
+	}
+
+	langs := make([]string, 0, len(procMap))
+	for lang := range procMap {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	ncpu := runtime.NumCPU()
+	if cfg.verbosity >= VerbosityLite && len(langs) > ncpu {
+		fmt.Fprintf(diag, "--isolate: %d tasks but only %d CPUs, some will share\n", len(langs), ncpu)
+	}
+	out := make(map[string][]string, len(procMap))
+	for i, lang := range langs {
+		cpu := strconv.Itoa(i % ncpu)
+		out[lang] = append([]string{taskset, "-c", cpu}, procMap[lang]...)
+		if cfg.verbosity == VerbosityHeavy {
+			fmt.Fprintf(diag, "[DEBUG] %s pinned to CPU %s\n", lang, cpu)
+		}
+	}
+	return out
+}
+
+// affinityTaskset resolves taskset for --affinity-rotate, or returns "" with
+// a warning when it's missing.
+func affinityTaskset() string {
+	taskset, err := exec.LookPath("taskset")
+	if err != nil {
+		warnf("--affinity-rotate needs taskset, running iterations unpinned")
+		return ""
+	}
+	return taskset
+}
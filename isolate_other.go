@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+// isolateTasks is a no-op off Linux, where taskset isn't available.
+func isolateTasks(procMap map[string][]string, cfg config) map[string][]string {
+	warnf("--isolate only works on Linux, running tasks unpinned")
+	return procMap
+}
+
+// affinityTaskset is "" off Linux, so --affinity-rotate does nothing.
+func affinityTaskset() string {
+	warnf("--affinity-rotate only works on Linux, running iterations unpinned")
+	return ""
+}
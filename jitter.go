@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// minTiming is the shortest timing ptrsg trusts to carry variance. Below it
+// the run was likely faster than the clock can resolve, and the 8 bytes it
+// adds to the hash are close to a constant.
+const minTiming = 10 * time.Microsecond
+
+// jitterSamples is how many back-to-back clock reads clockJitter folds.
+const jitterSamples = 256
+
+// clockJitter folds the gaps between consecutive monotonic clock reads into
+// one value. Each gap depends on caches, interrupts and frequency scaling at
+// that instant, so it varies even when the timings themselves don't.
+func clockJitter() uint64 {
+	var acc uint64
+	prev := time.Now()
+	for i := 0; i < jitterSamples; i++ {
+		now := time.Now()
+		acc = acc*31 + uint64(now.Sub(prev))
+		prev = now
+	}
+	return acc
+}
+
+// shortTimings returns, sorted, the languages whose timing is under
+// minTiming, warning about each.
+func shortTimings(timings map[string]int64, cfg config) []string {
+	var short []string
+	for lang, ns := range timings {
+		if time.Duration(ns) < minTiming {
+			short = append(short, lang)
+		}
+	}
+	sort.Strings(short)
+	for _, lang := range short {
+		warnf("%s took only %s, below what the clock resolves well; mixing in clock jitter", lang, time.Duration(timings[lang]))
+	}
+	return short
+}
+
+// resolutionSamples is how many clock reads clockResolution looks at.
+const resolutionSamples = 1 << 16
+
+// coarseClock is the resolution above which ptrsg calls the clock coarse.
+// Anything finer is already well below the low bits of a typical timing.
+const coarseClock = time.Microsecond
+
+// minTicks is how many clock ticks a timing should span on a coarse clock
+// before its low bits are worth anything.
+const minTicks = 1000
+
+// clockResolution estimates the monotonic clock's effective resolution as
+// the smallest nonzero step between back-to-back reads. If the clock never
+// moves within resolutionSamples reads, it's at least the time they took.
+func clockResolution() time.Duration {
+	start := time.Now()
+	prev := start
+	var res time.Duration
+	for i := 0; i < resolutionSamples; i++ {
+		now := time.Now()
+		if d := now.Sub(prev); d > 0 && (res == 0 || d < res) {
+			res = d
+		}
+		prev = now
+	}
+	if res == 0 {
+		res = max(time.Since(start), 1)
+	}
+	return res
+}
+
+// coarseTimings warns, when the clock is coarse, about every timing that
+// spans fewer than minTicks of it: those are quantized down to a handful of
+// values and give the hash very little.
+func coarseTimings(timings map[string]int64, cfg config) {
+	if cfg.clockResolution <= coarseClock {
+		return
+	}
+	var coarse []string
+	for lang, ns := range timings {
+		if time.Duration(ns) < cfg.clockResolution*minTicks {
+			coarse = append(coarse, lang)
+		}
+	}
+	if len(coarse) == 0 {
+		return
+	}
+	sort.Strings(coarse)
+	warnf("%s span fewer than %d ticks of the %s clock; raise --iterations or pick a heavier --workload",
+		strings.Join(coarse, ", "), minTicks, cfg.clockResolution)
+}
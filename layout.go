@@ -0,0 +1,27 @@
+package main
+
+import "encoding/binary"
+
+// bufferLayouts are the hash buffer layouts --buffer-layout can pick, in
+// the order they were introduced. v1 is the original. v2 starts with
+// layoutV2Tag and puts each language name's length in front of it, so a
+// buffer can only be read one way. Anything added later gets a new version
+// rather than changing an old one. The README's Hash buffer section is the
+// one place each layout's exact byte order is written down.
+var bufferLayouts = []string{"v1", "v2"}
+
+// defaultBufferLayout is the layout used when --buffer-layout isn't given,
+// and the one results recorded without a bufferLayout field used.
+const defaultBufferLayout = "v1"
+
+// layoutV2Tag is the first thing in a v2 buffer, ahead of the salt.
+const layoutV2Tag = "ptrsg/v2"
+
+// layoutName is how the buffer writes a language's name under layout: as
+// is for v1, after its length as 2 bytes big-endian for v2.
+func layoutName(layout, lang string) []byte {
+	if layout != "v2" {
+		return []byte(lang)
+	}
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(lang))), lang...)
+}
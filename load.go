@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// loadPollInterval is how often --wait-for-load rechecks the load average.
+const loadPollInterval = 5 * time.Second
+
+// checkLoad enforces --max-load. It returns an error if the 1-minute load
+// average is above the limit, or with --wait-for-load blocks until it drops.
+// Platforms without a load average get a warning and no check.
+func checkLoad(cfg config) error {
+	for {
+		load, err := loadAverage()
+		if errors.Is(err, errors.ErrUnsupported) {
+			warnf("--max-load isn't supported on this platform, ignoring it")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading load average: %w", err)
+		}
+		if load <= cfg.maxLoad {
+			return nil
+		}
+		if !cfg.waitForLoad {
+			return fmt.Errorf("load average %.2f is above --max-load %.2f; try again when the machine is quieter or add --wait-for-load", load, cfg.maxLoad)
+		}
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "Load average %.2f is above %.2f, waiting...\n", load, cfg.maxLoad)
+		}
+		time.Sleep(loadPollInterval)
+	}
+}
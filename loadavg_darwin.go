@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// loadAverage returns the 1-minute load average from the vm.loadavg sysctl,
+// a struct loadavg of three fixed-point uint32s followed by the long scale.
+func loadAverage() (float64, error) {
+	b, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < 24 {
+		return 0, errors.New("vm.loadavg: short read")
+	}
+	ld := binary.LittleEndian.Uint32(b[0:4])
+	scale := binary.LittleEndian.Uint64(b[16:24])
+	if scale == 0 {
+		return 0, errors.New("vm.loadavg: zero fscale")
+	}
+	return float64(ld) / float64(scale), nil
+}
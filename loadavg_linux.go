@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadAverage returns the 1-minute load average from /proc/loadavg.
+func loadAverage() (float64, error) {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func loadAverage() (float64, error) {
+	return 0, errors.ErrUnsupported
+}
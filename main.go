@@ -3,26 +3,243 @@ package main
 /*
 ⚠️ This tool uses flags ⚠️
 
-Verbose accepts none, lite, or heavy. It's automatically set to none. lite gives some useful info while heavy logs everything it can. An example use of verbose would be --verbose lite
+Verbose accepts none, lite, or heavy. It's automatically set to none. lite gives some useful info, including [n/total] progress as tasks compile and finish, while heavy logs everything it can and finishes with a key=value block between === SUMMARY === and === END SUMMARY === for scripts. An example use of verbose would be --verbose lite
+
+Print-command writes every compile and run command to stderr just before it runs, like set -x: one "+ " line each, shell-quoted, with a cd for commands run from the temp directory and any environment variables ptrsg adds, so a task can be copied out and run by hand. A command repeated over --iterations is printed once. It's narrower than --verbose heavy, which interleaves everything else too.
 
 Queue lets you decide if you want to queue up the languages being ran instead of running them simultaneously. It's just --queue, no additional stuff. If you queue it *MIGHT* reduce CPU strain.
 
 Chaos decides how many languages to use. low chaos runs a few languages that were in ptrsg 1.0.0 while high chaos, the default, runs ALL languages.
 
+Fallback-chaos lets a high chaos run carry on at low when preflight finds tools missing that only high needs, like php or rustc, and says so on the diagnostic output. If low is missing tools too, preflight fails as usual. Languages asked for outright with --extra-langs still have to be there.
+
 S is the flag for how long the seed should be, 1-512. Basically it either prints the entire full seed (512) or cuts it down a bit. An example command would be -S 128.
+
+Seed-bytes is the same thing in bytes, 1-64, for when whatever takes the seed counts in bytes: --seed-bytes 16 is -S 128. Giving both is an error.
+
+Seed-format picks how the seed gets printed: decimal (the default), hex, or uuid. hex is zero-padded to the full -S width, and --hex-prefix puts 0x in front of it. uuid takes the first 16 bytes and sets the version 4 and variant bits, so it needs -S 128 or more.
+
+Verify prints the first N values the seeded PRNG produces, one per line after the seed, like --verify 3. Two machines that agree on the seed agree on these, which is a quick way to check a seed was carried over correctly.
+
+Timings-only stops once the tasks are timed and prints just the timings on stdout, as the plain or table list from --format or with --json as a small object of timings (plus runs with --iterations). Nothing is hashed and no seed is derived, so anything that needs one, like --pool, --verify or --emit-bytes, can't go with it. It's the quickest way to get benchmark numbers.
+
+Template reshapes the seed line for whatever reads it, like --template "seed={seed} bits={bits}". {seed} is the seed in --seed-format, {bits} its width, {chaos} the chaos level and {hash} the full digest in hex; anything else is printed as is. Without it the line is the usual "Seed generated (N-bit): ...". --sweep and --seed-count print one templated line per seed.
+
+Permute prints 0 to N-1 in an order shuffled by the seeded PRNG, one per line after the seed (and any --verify values), like --permute 10. It's what you'd use for a randomized test order or picking a sample, and the same seed always gives the same order.
+
+Color highlights [DEBUG] and warning markers, language names and errors with ANSI colors. auto, the default, only does it on a terminal and never when NO_COLOR is set; always and never override that.
+
+Format changes how lite and heavy verbosity print the timings. plain, the default, is the usual list; table lines them up with each language's share of the total, slowest first.
+
+Time-unit shows those timings in ns (the default), us or ms, like --time-unit ms, and --json then adds them as strings under displayTimings next to timeUnit. It's only for reading: the hash, the json timings field and the heavy SUMMARY block stay in whole nanoseconds.
+
+Sweep prints the seed at 32, 64, 128, 256 and 512 bits, all cut from the same hash, in place of the usual seed line. Handy for seeing what -S actually does to the output.
+
+Raw-hash prints the full 512-bit blake2b digest as hex and nothing else. -S, --seed-format and the PRNG are skipped, so it's for when you want to do your own derivation on top.
+
+Profile takes a file path and appends each run's timings to it as one JSON line, like --profile history.jsonl. Add --profile-summary to read that file back and print trend stats instead of running. A path ending in .gz is gzip-compressed, with every run appended as a new gzip member so nothing already written is touched; --pool takes .gz paths the same way.
+
+Cpp-threads swaps the cpp task's sort for a parallel one, std::sort with std::execution::par, built as C++17 and linked against TBB when that's installed. The timing then depends on how the threads get scheduled across cores, which varies a lot more than a single thread does. Without parallel algorithms in the standard library it quietly falls back to the sequential sort. It only changes high chaos, where cpp runs, and only --workload sort.
+
+Cpp-compilers builds the cpp task once per compiler, like --cpp-compilers gcc,clang, and times each build as its own language, cpp-gcc and cpp-clang, in place of cpp. gcc builds with g++ (or --cxx) and clang with clang++, and preflight checks for each. Two compilers make two different binaries from the one snippet, so that's another independent timing for free. Like cpp, it only changes high chaos.
+
+File-mode sets the permissions of the task sources written to the temp directory, like --file-mode 0600, in place of 0644. Compiled binaries are chmodded to match, with the execute bit added wherever the read bit is, so 0600 gives 0700 binaries; --compile-only copies get the same. The owner always needs read and write. Without it, binaries keep whatever mode the compiler gave them.
+
+cflags-cpp, cflags-rust and gcflags pass extra flags to g++, rustc and go build (as -gcflags) when compiling the tasks, like --cflags-cpp "-march=native". Flags that change the output path are rejected.
+
+Preflight reads the cc and g++ --version banners to tell gcc from clang, since both names are often clang, and always are on macOS. For clang the C and C++ builds get -Qunused-arguments and -Wno-unknown-warning-option ahead of any --cflags-cpp, so flags written for gcc warn no more than gcc would. Heavy verbosity says which family was found, and --compiler-check and --freeze report it.
+
+JSON prints the result as a single JSON object instead of the usual seed line. The object carries a schemaVersion field that gets bumped whenever a field is renamed, removed or changes meaning; new fields can appear without a bump, so readers should ignore ones they don't know. When preflight fails it prints an object with error set to preflight and a missing list naming each tool, the binary looked for and the probe error.
+
+Bundle writes one self-describing JSON file per run for audit trails, like --bundle run.json: the --json result with the --freeze environment, the seed in every --seed-format, the flags given on the command line (with --key's value left out) and a UTC createdAt timestamp. It's written on top of the normal output, and works with replay too.
+
+Stats prints entropy accounting before the seed: how many bits the hash produced, how many -S kept, and how many actually reach the PRNG. With --iterations it also shows how much each language's runs vary, as a coefficient of variation, and an overall stability score from them: low means a good benchmark and a weak entropy source, high the opposite.
+
+Tmpdir picks where the task files get written and run from, like --tmpdir ~/ptrsg-tmp. Handy when the system temp dir is mounted noexec.
+
+Compare takes a file saved from an earlier --json run and prints how much each language's timing changed since then, like --compare last.json.
+
+Output writes the raw seed bytes to a file, like --output seed.bin. unix:///run/seed.sock sends them to a Unix socket and fifo:///tmp/seeds to an existing named pipe instead. Emit-bytes seeds the PRNG and writes that many pseudo-random bytes instead, to --output if given or stdout otherwise, like --emit-bytes 1024.
+
+Rand-impl picks the PRNG behind --verify, --permute and --emit-bytes. v1, the default, is math/rand seeded with the seed's low 64 bits, as it always has been. v2 is math/rand/v2's ChaCha8 keyed with the blake2b-256 of the whole seed, so -S up to 256 reaches the generator and the stream is a CSPRNG's. The two give different values for the same seed.
+
+Quiet drops the "Seed generated" line and sends every diagnostic to stderr, so stdout only carries the payload. It's implied by --emit-bytes without --output.
+
+Retries makes a failed compile or run try again with a short, growing backoff before giving up, like --retries 3. Useful on Windows where AV sometimes locks a freshly written exe.
+
+Pool turns ptrsg into an entropy accumulator, like --pool pool.bin. Before hashing, the last 64 bytes of the file are appended to the timing buffer, and afterwards the new seed bytes get appended to the file, so every run feeds the next one.
+
+Seed-count derives several independent seeds from a single measurement, like --seed-count 4. Each one hashes the timing buffer with a 4-byte big-endian counter (0, 1, 2, ...) on the end, so it's much cheaper than re-running the tasks.
+
+Require sets minimum tool versions, like --require "node>=18,python>=3.10,rustc>=1.70", checked against the versions preflight reads from each --version banner. A tool that's older, or whose version can't be read, fails preflight. Tools the run doesn't use aren't checked, so one list can cover both chaos levels.
+
+cc, cxx, rustc, go, tinygo, zig, swiftc, ghc, lua, python, node, php and perl each pick the binary used for that tool, like --cxx g++-13 or --python python3.12. Names are looked up on the PATH as usual and full paths work too. Preflight, compiling and running all use it, so a run is pinned to exactly those toolchains.
+
+Timing-precision rounds every timing, and every --fold all run, to the nearest ns, 10ns, 100ns or us before it goes into the hash. The lowest bits of a timing are mostly clock jitter: keep them at the default ns for the most entropy, or round them away for seeds that repeat more often when testing. The reported timings stay exact, and replay needs the same precision to get the same seed.
+
+Debias swaps the raw timings in the hash buffer for a von Neumann debiased stream of their low 16 bits: each pair of bits becomes 0 for 01, 1 for 10, and nothing for 00 or 11. That strips any steady bias from the noisy bits before blake2b sees them, at the cost of most of the bits, so it works best with plenty of languages. It changes every seed, and replay needs it too.
+
+Sample-clock picks what each timing measures. monotonic, the default, is wall time on the monotonic clock, so it picks up scheduling, I/O and everything else happening on the machine. process-cpu is the user plus system CPU time the task's process reported when it exited, which ignores time spent waiting but is often much coarser, down to scheduler ticks on some systems. It can't be combined with --exclude-startup or --persistent.
+
+Mix-binsize adds the size in bytes of every compiled task's executable to the hash, after the perf counters. Sizes barely change between runs on one machine but differ between compiler versions and flags, so they set environments apart for the price of a stat call each.
+
+Include-compile-time adds how long each compiled task took to build, in ns, to the hash after everything else the tasks report. Compile times are noisy and system-dependent in the same way run timings are, so it's several more samples for no extra processes. Under --stream the one build's times go into every round.
+
+Freeze adds an "environment" object to the --json output with the OS and architecture, CPU model and count, the Go runtime ptrsg was built with, the workload, iterations and compiler flags, and every tool preflight probed with its path and version. Two results are only worth comparing as benchmarks when those match. It needs --json.
+
+Collect-stderr captures what each task writes to stderr, up to 64 KiB of it, and mixes the total byte count and a blake2b-256 digest of the captured bytes into the hash after the context switches. Warnings, JIT chatter and other diagnostics that change from run to run then count too. With --iterations the runs' stderr is joined before hashing.
+
+Mix-ctxsw adds how many context switches each task went through, voluntary and involuntary together from its rusage, to the hash after the binary sizes. That count depends on what else the scheduler was juggling at the time, so it's noise that doesn't come from the clock. Like --measure-memory it only works on unix and is ignored with a warning elsewhere.
+
+Mix-aslr has every task print the address of something it just allocated, a stack local in the compiled ones, which ASLR moves from run to run, and mixes those in after the compile times. It's a channel that has nothing to do with the clock. lua, python, perl, c, cpp and rust can print one; the rest, and any --extra-cmd or manifest task that doesn't print a "ptrsg-addr: 0x..." line itself, add 0. Not with --persistent.
+
+Bench-baseline times a fixed arithmetic loop inside ptrsg itself once the tasks are done and prints every timing as a multiple of it, like "go: 2.31x". Raw timings depend on the machine; the ratios mostly don't, which makes runs from different machines comparable. It's only reported and never touches the seed.
+
+Reorder-guard rederives each run's hash from its measurements collected in ascending and then descending language order, as queue and parallel runs would finish in different orders, and fails the run if the two disagree. It only covers the deterministic part; clock jitter, the pool, the beacon and --mix-os-entropy are left out of the check.
+
+Endian picks the byte order each 8-byte timing is written in, big (the default) or little, for matching an outside tool that rebuilds the hash buffer. Only the timings change; exit codes, memory, counters and the rest stay big-endian. Replay needs the same choice.
+
+A seed timed from fewer than three languages, which --min-langs, --max-runtime or --retry-measure can let through, still gets printed, but after a "WARNING: low-entropy seed (N sources)" line on stderr. --stats shows the rough entropy estimate behind it: a quarter of the 16 noisy low bits per timing, capped at -S.
+
+Weight makes one language count for more in the hash, like --weight rust=3, by writing its timing into the buffer that many times instead of once. It's for when you've profiled your machine and know which languages vary the most. Every language defaults to 1, and replay needs the same weights to get the same seed.
+
+Extra-cmd times a program you already have as one more language, like --extra-cmd "bench=./mybench --quick". It can be given several times, and each name has to be unique. The command is split on spaces, not run through a shell.
+
+Manifest adds your own languages from a JSON file, like --manifest langs.json. Each entry has a name, a source, the source file's ext, an optional compile command and a run command (both as argument lists), and the commands can use {src}, {exe} and {dir}. They're written, built and timed alongside the built-in tasks and hashed the same way. Entries with the same ext, source and compile command are built once and share the binary. See the README for an example.
+
+No-interpreted drops every interpreted task, lua, python and node plus php and perl on high chaos, so only the compiled ones the chaos level picks are timed, along with any --extra-langs, manifest and --extra-cmd languages. Preflight then doesn't look for the interpreters at all.
+
+Extra-langs adds compiled languages that no chaos level includes, like --extra-langs zig. Their toolchains are only checked for in preflight when they're asked for. Right now that's zig, built with zig build-exe in Debug mode, swift, built with swiftc -Onone for anyone on a Mac who has it anyway, and haskell, built with ghc -O0.
+
+Go-compiler picks what builds the go task, go (the default) or tinygo, like --go-compiler tinygo. TinyGo's codegen is very different so it gives its own timing profile. tinygo is only checked for in preflight when it's selected.
+
+Workload picks what each task actually does: sort (the default) builds and sorts strings, hashmap fills and reads back a string-keyed map, and arith runs a tight modular arithmetic loop. Each one leans on a different part of the CPU, like --workload arith. io is the odd one out: each task writes a 1 MiB file to the system temp directory in 4 KiB chunks, reads it back and deletes it, so the timings pick up filesystem and kernel latency instead. Nothing is fsynced, so on most systems that's the page cache more than the disk.
+
+Mix-exit-codes also hashes each task's exit code (4 bytes each, in language order, after the timings and any --fold all runs), so a task that bails out early changes the seed in a way the timing alone wouldn't. With it on, a task exiting nonzero isn't treated as a failure.
+
+Running ptrsg selftest (flags still apply) runs the whole pipeline a few times back to back and checks every seed came out different, printing PASS or FAIL.
+
+Running ptrsg replay result.json rederives the seed from a saved --json result without running anything, using the current -S, --key, --salt and output flags. The recorded exit codes, memory, perf counters, binary sizes and beacon value are mixed back in; the pool tail and clock jitter can't be, so a run that used those won't replay to the same seed, and neither will one made with --mix-os-entropy.
+
+Buffer-layout picks which version of the hash buffer layout the seed comes from, so anyone deriving seeds independently has a fixed target: v1, the default, is the original, and v2 starts with the tag "ptrsg/v2" and puts each language name's length in front of it. A released layout never changes. The layout is recorded in --json and --bundle output as bufferLayout, and replay uses the recorded one unless told otherwise.
+
+Max-runtime puts a wall-clock budget on the run phase, like --max-runtime 10s. Once it's used up no more tasks start and running ones get killed, and the seed comes from whatever finished, as long as at least --min-langs languages did (1 by default).
+
+Retry-measure makes run failures work the same way, like --retry-measure 2 --min-langs 4: a task that fails is left out as long as --min-langs languages finished. When too few did, the failed ones are run again one at a time, up to that many rounds, before the run gives up. Compile failures still end the run.
+
+Timeout-lang sets limits for single languages and compilers, like --timeout-lang node=2s,rustc=30s. A language's name limits each of its runs, and a run that goes over is killed and fails like any other failed run, so a hang shows up in seconds. A compiler's name, as in rustc, g++, cc, go or a manifest compile command, limits each of its compiles in place of --compile-timeout. go names both the go task and go build. Names this run doesn't use are rejected.
+
+Compile-timeout kills any single compile that takes longer than the given duration, like --compile-timeout 2m, and fails the run naming the language that stalled. It's for toolchains that can hang, such as a rustup proxy waiting on the network. Each --retries attempt gets the full timeout.
+
+Assert-bits fails the run if a seed's big integer has fewer significant bits than asked for, like --assert-bits 120 with -S 128. Leading zero bits are normal after truncation, so add --assert-attempts 3 to measure again a few times before giving up.
+
+Min-spread fails the run if the slowest and fastest timings are closer together than the given duration, like --min-spread 5ms. A tiny spread means the machine isn't giving the timings much to vary with. --assert-attempts re-measures for this too. It needs at least two languages to mean anything.
+
+Beacon fetches the latest value from a public randomness beacon and mixes it into the hash after the local measurements, like --beacon https://api.drand.sh/public/latest. drand rounds (randomness) and NIST beacon 2.0 pulses (pulse.outputValue) are understood. Anyone can check the beacon value afterwards, so the seed is tied to public randomness as well as to this machine's timings. It's kept in --json output and replay mixes it back in. If the beacon can't be fetched the run fails, unless --beacon-on-error warn, which carries on without it.
+
+Commit is for commit-reveal protocols: instead of the seed it prints a commitment to it and the nonce behind it, and saves the commitment, nonce, -S and seed to a new file, readable only by its owner. The commitment is SHA-256 over the seed as ceil(-S/8) big-endian bytes, the same bytes --output writes, followed by a 32-byte nonce from the OS CSPRNG. Publish the commitment; later, --reveal file checks the saved seed and nonce against it, and against --commitment hex if that's given, and prints the seed. Nothing that prints or passes on the seed can be combined with it.
+
+Mix-os-entropy XORs the seed with the same number of bits from crypto/rand, the OS CSPRNG. XOR with an independent uniform value is uniform, so the seed is never weaker than the OS source even if an attacker could predict every timing, and the timings still contribute. The hash printed by --raw-hash and --json is the timing hash alone.
+
+Key turns the hash into keyed blake2b, like --key my-app. Two apps seeding off the same machine at the same moment get unrelated seeds as long as their keys differ. Up to 64 bytes; without it nothing changes.
+
+Salt is written at the start of the hash buffer, ahead of the timings and after only the v2 tag, like --salt deploy-eu-1. It does the same job as --key without needing a keyed hash, so it works however the buffer ends up hashed. The README's Hash buffer section gives the exact byte order of each --buffer-layout.
+
+Snapshot-timings caches a measurement for later runs, like --snapshot-timings seed.json. The first run measures as usual and saves its --json result there; every run after that finds the file and replays it instead, with no preflight and nothing timed, so a test suite gets the same seed each time. Delete the file to measure again. Runs whose hash takes in the pool, clock jitter or --mix-os-entropy can't be replayed exactly, as with replay.
+
+Exec passes the seed straight to another program, like --exec "mytool --seed {seed}". {seed} is replaced with the seed as --seed-format prints it, {hex} with the raw seed bytes in hex, and {raw} with the path of a temp file holding those bytes, which is deleted when the program exits. Like --extra-cmd it's split on spaces, not run through a shell. It implies --quiet so the program has stdout to itself, and ptrsg exits with its exit code. Add --env-var NAME to hand the seed over in the environment as well, formatted per --seed-format, like --exec ./mytool --env-var MYTOOL_SEED; nothing then needs substituting, so the seed stays out of argv and ps.
+
+Hash-rounds makes the derivation deliberately slow, like --hash-rounds 1000000: after the buffer is hashed, the 64-byte digest is hashed again (with the same --key) until there have been that many rounds in all. Someone guessing at the timings behind a seed then pays that cost per guess, which matters most when the timings don't carry much entropy. 1, the default, is a single hash. Every value gives different seeds and replay needs the same one.
+
+Dump-buffer writes the hash buffer for the first seed to a file, like --dump-buffer buf.bin, for running it through your own hash or KDF. The bytes are exactly what blake2b sees; --key keys the hash rather than joining the buffer, and --mix-os-entropy is XORed into the seed afterwards, so neither is in it. Add --dump-buffer-only to stop there without printing the seed.
+
+Any timing under 10µs is too close to the clock's resolution to carry much variance, so ptrsg warns about it and mixes extra clock-jitter samples into the hash to make up for it.
+
+Version prints the PTRSG version along with the Go version and OS/arch it was built for, then exits before doing anything else.
+
+Compiler-check runs preflight, prints the name, parsed version and path of every tool the current flags need, then exits. Tools it can't find show up as missing and make it exit 3. Pair it with --json for a machine-readable list.
+
+Precompile compiles python (py_compile) and lua (luac) tasks to bytecode first and times running that instead, so parse time drops out. If luac isn't around, or compiling fails, that language just runs from source.
+
+Isolate pins each task to a different CPU with taskset so parallel tasks stop stepping on each other. Linux only; elsewhere it warns and does nothing.
+
+Container runs every task in a fresh container from the given image instead of on the host, like --container ptrsg-bench:latest, using docker or else podman. The temp directory is bind-mounted at the same path, so compiled tasks are still built on the host and have to run in the image; interpreters are looked up by name on the image's PATH. Every timing then includes starting the container, which dominates short tasks. Things that measure the process from outside, like --perf, --measure-memory or --isolate, can't be used with it.
+
+Affinity-rotate pins each task's runs to CPUs in turn instead, like --iterations 8 --affinity-rotate: run i goes on CPU i mod the CPU count, so the runs don't all find the same warm caches and steady clock on one core. It needs taskset, can't be combined with --isolate and does nothing under --persistent, where there's only one process. Linux only; elsewhere it warns and does nothing.
+
+Prime-binaries runs every compiled task once, unmeasured, before the real run. On Windows the first launch of a fresh exe gets scanned by antivirus, and without this that delay ends up in the timing.
+
+Task-threads sets GOMAXPROCS, RAYON_NUM_THREADS, OMP_NUM_THREADS and UV_THREADPOOL_SIZE for every task, like --task-threads 1, so the runtimes don't pick their own thread counts. 0, the default, leaves the environment alone.
+
+Measure-memory reads each task's peak RSS from its rusage after it exits and mixes that into the hash next to the timing. It only works on unix; elsewhere it warns and does nothing.
+
+Exclude-startup has python, node, php and perl time their own snippet and print the result, which replaces the process wall time so interpreter startup drops out. lua and the compiled tasks can't do that yet and keep the wall time.
+
+Concurrency-model is simple (the default) or pipeline. simple compiles every task before timing anything; pipeline starts the compiles first, before even the interpreted tasks are written, then starts the interpreted tasks right away and adds each compiled one as soon as it's built, which cuts total time on high chaos. The compiles then share the machine with the measured runs, and --max-runtime counts from the start of the compiles. pipeline doesn't work with --prime-binaries or --isolate.
+
+Before timing anything ptrsg checks how finely the clock ticks, and --verbose heavy prints what it found. A clock coarser than 1µs, like the 15ms some Windows setups have, gets a warning, and so does any timing shorter than a thousand of its ticks, since those only land on a few distinct values. The fix is more --iterations or a heavier --workload; ptrsg doesn't change either on its own, because that would change the seed.
+
+Stress is the opposite of --max-load, like --stress 4: that many goroutines spin on the CPU inside ptrsg for as long as the tasks are being timed, and stop before the seed is derived. The tasks then fight the scheduler for every slice, which widens their timings on purpose. Compiles aren't affected unless --concurrency-model pipeline overlaps them with the runs.
+
+Max-load refuses to run when the 1-minute load average is above the given number, like --max-load 1.5, since a busy machine adds noise rather than entropy. Add --wait-for-load to wait for it to drop instead. Linux and macOS only.
+
+Dither sleeps for a random 0-5ms, drawn from crypto/rand, before launching each task. Parallel tasks then hit shared resources at different moments every run instead of in lock-step. The sleep happens before the timer starts.
+
+Perf runs each task under perf stat and mixes a hardware counter into the hash alongside the wall time. The counter is --perf-event, instructions by default; cache-misses or branch-misses work too. Without perf on the PATH it warns and carries on with wall time only.
+
+Fail-fast is on by default, so the first task that fails to build or run ends the run. --fail-fast=false still builds and runs every language, then reports all the failures together and exits nonzero, which is handy when setting up a new machine.
+
+Best-effort goes one further: when tasks fail to build or run, the seed is derived from the languages that did finish, as long as --min-langs of them did, and the failures are printed as a warning instead. The result is marked degraded, with the usual low-entropy warning and "degraded" in --json, since it's weaker than a full run. It implies --fail-fast=false.
+
+Strict makes every warning fatal: a degraded seed, a coarse clock, clamped or jitter-mixed timings, languages left out after failing, low disk space, a platform that can't do what a flag asks, a replay that doesn't match. The warning is still printed, then ptrsg exits 1 before any seed is. It rules out --best-effort and --beacon-on-error warn.
+
+Iterations runs every task that many times in a row, like --iterations 20. The hashed timing for each language is the sum of its runs, so each run's variance counts. --histogram then draws an ASCII histogram of each language's runs under --verbose lite, which is a quick way to see whether a language really varies.
+
+Drop-outliers leaves the fastest and slowest runs of each language out, --outlier-percent of them from each end (10 by default, rounded down), before they're summed, hashed or folded in with --fold all. One GC pause or scheduler stall then can't swamp the other runs, which makes for steadier timings when benchmarking. It needs enough --iterations to drop at least one run from each end, 10 at the default. --json and --histogram only show the runs that were kept, so replay gets the same seed.
+
+Fold decides how much of those runs the hash sees. aggregate, the default, is just the sum. all keeps the sum and adds every run's own timing after it, by language and then run, so a language that's slow to launch gives --iterations samples instead of one. Replay needs the same --fold.
+
+Explain-chaos prints which languages a chaos level runs and what builds each compiled one, like --explain-chaos low, then exits. --extra-langs, --go-compiler, --manifest and --extra-cmd are taken into account, since they add to either level.
+
+Dump-sources prints every snippet this run would write to disk, manifest languages included, and exits without checking for or running any tools. It follows --chaos, --workload, --extra-langs and --exclude-startup, so what it prints is exactly what would run.
+
+Compile-only builds the compiled tasks for the current --chaos, --extra-langs and --manifest, copies the executables into the given directory and exits without timing anything or printing a seed. Preflight still checks every tool.
+
+Check-compile builds the same tasks in a throwaway directory and, instead of keeping them, prints whether each one compiled, with the compiler's output under any that didn't, then exits nonzero if any failed. Nothing is run or timed. A failed build's error carries the compiler's output in every mode, so this just collects them all in one place.
+
+Verify-reproducible builds the same compiled tasks twice, in the same temp directory, and prints each language's two blake2b-256 binary digests with whether they're byte-identical. It's a diagnostic for whether the compile step is deterministic; --json prints the results as a builds object. A difference isn't an error, a failed build is. Nothing is timed.
+
+Stream keeps ptrsg running: it compiles everything once, then times the tasks again and prints a new seed every interval, like --stream 5s, until it gets Ctrl-C or SIGTERM. Each round is reported just like a normal run. --assert-bits, --min-spread and --profile don't apply, and the compiles always finish before the first round whatever --concurrency-model says.
+
+Http makes a --stream run a small seed service, like --stream 1m --http :8080. GET /healthz answers ok while the last measurement succeeded within two intervals (plus however long it took), and 503 otherwise or before the first one. GET /seed times the tasks once more on the spot and returns that seed in --seed-format. Measurements queue rather than overlap, and the server shuts down with the stream on SIGINT or SIGTERM.
+
+Metrics-format prometheus adds GET /metrics to --http, in the Prometheus text format, for scraping alongside everything else: ptrsg_task_duration_seconds per language from the last good measurement, counters of measurements that worked and failed, and when the last good one finished. Scraping reads what the stream already measured and never times anything itself.
+
+Persistent starts lua, python and node once and hands them each --iterations run over stdin, timing the round trip, instead of paying for a fresh interpreter every time. The snippet is loaded once and run with fresh globals each round. Other languages still spawn per iteration. It can't be combined with --exclude-startup, --precompile or --perf.
+
+Parallel caps how many tasks compile or run at the same time, like --parallel 2. It's 0 by default, which means no limit. --queue still runs everything one at a time.
 */
 
 import (
 	"bytes"
+	"context"
+	crand "crypto/rand"
 	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"math/big"
-	"math/rand"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +249,15 @@ import (
 
 const version = "2.1.0 [Go]"
 
+// schemaVersion is the version of the --json output shape. Bump it whenever
+// a field is renamed, removed or changes meaning. Adding a field doesn't
+// need a bump; readers are expected to ignore fields they don't know.
+const schemaVersion = 1
+
+// hashBits is the width of the digest deriveSeed truncates, and so the
+// largest -S there is.
+const hashBits = blake2b.Size * 8
+
 type Verbosity int
 
 const (
@@ -40,9 +266,136 @@ const (
 	VerbosityHeavy
 )
 
-func parseFlags() (Verbosity, bool, string, int) {
+// config holds everything parseFlags collected from the command line.
+type config struct {
+	verbosity          Verbosity
+	queue              bool
+	chaos              string
+	seedBits           int
+	profile            string
+	profileSummary     bool
+	cppFlags           []string
+	rustFlags          []string
+	goGCFlags          string
+	parallel           int
+	json               bool
+	stats              bool
+	tmpdir             string
+	compare            string
+	emitBytes          int
+	output             string
+	quiet              bool
+	retries            int
+	pool               string
+	seedCount          int
+	goCompiler         string
+	workload           string
+	mixExitCodes       bool
+	command            string
+	maxRuntime         time.Duration
+	compileTimeout     time.Duration
+	langTimeouts       map[string]time.Duration
+	stream             time.Duration
+	minLangs           int
+	key                []byte
+	salt               []byte
+	compilerCheck      bool
+	precompile         bool
+	nodeFlags          []string
+	isolate            bool
+	affinityTaskset    string
+	seedFormat         string
+	hexPrefix          bool
+	rawHash            bool
+	taskThreads        int
+	measureMemory      bool
+	excludeStartup     bool
+	concurrencyModel   string
+	maxLoad            float64
+	waitForLoad        bool
+	dither             bool
+	perfEvent          string
+	checkDeterminism   bool
+	extraLangs         []string
+	sweep              bool
+	verify             int
+	permute            int
+	timingsOnly        bool
+	require            []toolRequirement
+	hashRounds         int
+	exec               []string
+	envVar             string
+	noInterpreted      bool
+	collectStderr      bool
+	template           string
+	fallbackChaos      bool
+	printCommand       bool
+	fold               string
+	dropOutliers       int
+	http               string
+	metricsFormat      string
+	bundle             string
+	cppThreads         bool
+	cppCompilers       []string
+	fileMode           os.FileMode
+	verifyRepro        bool
+	randImpl           string
+	includeCompileTime bool
+	container          string
+	containerRuntime   string
+	manifestPath       string
+	compileOnly        string
+	checkCompile       bool
+	dumpSources        bool
+	manifest           []manifestLang
+	format             string
+	mixOSEntropy       bool
+	beacon             string
+	beaconOnError      string
+	commit             string
+	reveal             string
+	commitment         string
+	replayFile         string
+	snapshotTimings    string
+	failFast           bool
+	bestEffort         bool
+	hooks              Hooks
+	extraCmds          map[string][]string
+	weights            map[string]int
+	toolBinaries       map[string]string
+	color              string
+	assertBits         int
+	assertAttempts     int
+	minSpread          time.Duration
+	debias             bool
+	timingPrecision    int64
+	bufferLayout       string
+	persistent         bool
+	endian             string
+	sampleClock        string
+	reorderGuard       bool
+	benchBaseline      bool
+	mixBinsize         bool
+	mixCtxsw           bool
+	mixASLR            bool
+	strict             bool
+	freeze             bool
+	timeUnit           string
+	stress             int
+	clockResolution    time.Duration
+	explainChaos       string
+	retryMeasure       int
+	dumpBuffer         string
+	dumpBufferOnly     bool
+	iterations         int
+	histogram          bool
+	primeBinaries      bool
+}
+
+func parseFlags() config {
 	args := os.Args[1:]
 	verbosity := VerbosityNone
+	checkDeterminism := false
 	newArgs := []string{os.Args[0]}
 
 	for i := 0; i < len(args); i++ {
@@ -63,6 +416,10 @@ func parseFlags() (Verbosity, bool, string, int) {
 			} else {
 				verbosity = VerbosityHeavy
 			}
+		} else if args[i] == "--check-determinism" {
+			// Deliberately left out of --help; it's a guard for
+			// developers, not something users need.
+			checkDeterminism = true
 		} else {
 			newArgs = append(newArgs, args[i])
 		}
@@ -70,339 +427,3101 @@ func parseFlags() (Verbosity, bool, string, int) {
 
 	os.Args = newArgs
 
-	queue := flag.Bool("queue", false, "")
-	chaos := flag.String("chaos", "high", "")
-	seed := flag.Int("S", 512, "")
+	queue := flag.Bool("queue", false, "run each language one at a time instead of in parallel")
+	chaos := flag.String("chaos", "high", "how many languages to use: low or high")
+	seed := flag.Int("S", 512, "seed length in bits, 1-512")
+	seedBytes := flag.Int("seed-bytes", 0, "seed length in `bytes`, 1-64, instead of -S")
+	seedFormat := flag.String("seed-format", "decimal", "how to print the seed: "+strings.Join(seedFormats, ", "))
+	hexPrefix := flag.Bool("hex-prefix", false, "prefix --seed-format hex seeds with 0x")
+	verify := flag.Int("verify", 0, "after the seed, print the first `N` Int63 values of the PRNG it seeds")
+	permute := flag.Int("permute", 0, "after the seed, print a random permutation of 0..`N`-1 shuffled by the PRNG it seeds")
+	sweep := flag.Bool("sweep", false, "print the seed at several bit lengths from one measurement")
+	rawHash := flag.Bool("raw-hash", false, "print the full blake2b digest as hex instead of a seed")
+	workload := flag.String("workload", "sort", "what each task does: "+strings.Join(workloads, ", "))
+	precompileFlag := flag.Bool("precompile", false, "run python and lua from bytecode (py_compile, luac) where possible")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	compilerCheck := flag.Bool("compiler-check", false, "print the version and path of every required tool and exit")
+	profile := flag.String("profile", "", "append this run's timings to a JSONL history `file`")
+	profileSummary := flag.Bool("profile-summary", false, "print trend stats for the --profile history and exit")
+	cflagsCpp := flag.String("cflags-cpp", "", "extra `flags` for the g++ compile")
+	cflagsRust := flag.String("cflags-rust", "", "extra `flags` for the rustc compile")
+	gcflags := flag.String("gcflags", "", "-gcflags `value` for the go build")
+	nodeFlagsStr := flag.String("node-flags", "", "extra `flags` for node, placed before the script (e.g. --jitless)")
+	beacon := flag.String("beacon", "", "mix the latest value from the drand or NIST randomness beacon at `URL` into the hash")
+	beaconOnError := flag.String("beacon-on-error", "fail", "what to do when --beacon can't be fetched: fail, or warn and carry on without it")
+	commit := flag.String("commit", "", "print a SHA-256 commitment to the seed and its nonce instead of the seed, saving all three to `file` for --reveal")
+	revealFile := flag.String("reveal", "", "check the --commit `file` against its commitment and print the seed, without measuring anything")
+	commitment := flag.String("commitment", "", "with --reveal, the published commitment `hex` the file also has to match")
+	mixOSEntropy := flag.Bool("mix-os-entropy", false, "XOR the seed with -S bits from crypto/rand, so it's never weaker than the OS CSPRNG even if the timings are predictable")
+	toolFlagValues := make(map[string]*string, len(toolFlags))
+	for _, t := range toolFlags {
+		toolFlagValues[t.tool] = flag.String(t.flag, t.tool, "`binary` to use for "+t.tool)
+	}
+	var extraCmdFlags stringList
+	flag.Var(&extraCmdFlags, "extra-cmd", "time an existing program as an extra language, as `name=command args`; repeatable")
+	var weightFlags stringList
+	flag.Var(&weightFlags, "weight", "hash a language's timing `lang=N` times instead of once; repeatable")
+	bestEffort := flag.Bool("best-effort", false, "derive a degraded seed from the languages that finished when others fail, instead of exiting (implies --fail-fast=false)")
+	failFast := flag.Bool("fail-fast", true, "stop at the first failing task; --fail-fast=false tries every language and reports all failures")
+	iterations := flag.Int("iterations", 1, "run each task `N` times and hash the summed timing")
+	includeCompileTime := flag.Bool("include-compile-time", false, "mix how long each compiled task took to build into the hash")
+	mixBinsize := flag.Bool("mix-binsize", false, "mix each compiled task's executable size into the hash")
+	freeze := flag.Bool("freeze", false, "with --json, record the OS, CPU, toolchain versions and build flags under \"environment\"")
+	mixCtxsw := flag.Bool("mix-ctxsw", false, "mix each task's voluntary plus involuntary context switches into the hash (unix only)")
+	strict := flag.Bool("strict", false, "fail with a nonzero exit on any warning or a degraded seed, before printing the seed")
+	mixASLR := flag.Bool("mix-aslr", false, "have each task print an address that moves with ASLR and mix it into the hash")
+	benchBaseline := flag.Bool("bench-baseline", false, "also report each timing relative to a fixed in-process calibration loop")
+	reorderGuardFlag := flag.Bool("reorder-guard", false, "check the seed doesn't depend on the order the timings came in before using it")
+	sampleClock := flag.String("sample-clock", "monotonic", "what each timing measures: monotonic (wall time) or process-cpu (the task's user+system CPU time)")
+	endian := flag.String("endian", "big", "byte order of the timings in the hash buffer: big or little")
+	persistent := flag.Bool("persistent", false, "start lua, python and node once and feed them every --iterations run over stdin")
+	histogram := flag.Bool("histogram", false, "with --iterations and --verbose lite, draw each language's timing distribution")
+	assertBits := flag.Int("assert-bits", 0, "fail unless every seed has at least `N` significant bits")
+	assertAttempts := flag.Int("assert-attempts", 1, "with --assert-bits or --min-spread, measure up to `N` times before giving up")
+	bufferLayout := flag.String("buffer-layout", defaultBufferLayout, "hash buffer `layout` to derive the seed from: v1 or v2 (replay defaults to the recorded one)")
+	timingPrecision := flag.String("timing-precision", "ns", "round every timing to `STEP` (ns, 10ns, 100ns or us) before hashing")
+	debias := flag.Bool("debias", false, "hash a von Neumann debiased stream of the timings' low bits instead of the raw timings")
+	minSpread := flag.Duration("min-spread", 0, "fail unless the slowest and fastest timings are at least this `duration` apart")
+	color := flag.String("color", "auto", "color verbose output and errors: auto, always or never (auto honors NO_COLOR)")
+	format := flag.String("format", "plain", "how --verbose prints timings: plain or table")
+	timeUnit := flag.String("time-unit", "ns", "unit timings are displayed in: ns, us or ms")
+	manifestPath := flag.String("manifest", "", "JSON `file` of extra languages to time alongside the built-in ones")
+	verifyRepro := flag.Bool("verify-reproducible", false, "build the compiled tasks twice, report whether each pair of binaries is byte-identical and exit")
+	checkCompileFlag := flag.Bool("check-compile", false, "compile every compiled task, report which ones build, with the compiler output of those that don't, and exit")
+	compileOnlyDir := flag.String("compile-only", "", "build the compiled tasks into `dir` and exit without timing anything")
+	explainChaosLevel := flag.String("explain-chaos", "", "list the languages and compile steps chaos `level` (low or high) uses, and exit")
+	dumpSrc := flag.Bool("dump-sources", false, "print the source of every task this run would execute and exit")
+	extraLangsStr := flag.String("extra-langs", "", "comma-separated optional `languages` to add: "+strings.Join(optionalLangs, ", "))
+	goCompiler := flag.String("go-compiler", "go", "compiler for the go task: go or tinygo")
+	stress := flag.Int("stress", 0, "burn CPU on `N` background goroutines while the tasks are timed")
+	maxLoad := flag.Float64("max-load", 0, "refuse to run if the 1-minute load average is above `F` (0 disables the check)")
+	waitForLoad := flag.Bool("wait-for-load", false, "with --max-load, wait for the load to drop instead of refusing")
+	perf := flag.Bool("perf", false, "run each task under perf stat and mix a hardware counter into the hash (Linux)")
+	perfEventFlag := flag.String("perf-event", "instructions", "the perf `event` --perf counts")
+	dither := flag.Bool("dither", false, "sleep a random few ms before each task launch so parallel tasks don't lock-step")
+	concurrencyModel := flag.String("concurrency-model", "simple", "simple compiles everything before running, pipeline runs tasks while the rest compile")
+	prime := flag.Bool("prime-binaries", false, "run each compiled task once, unmeasured, before timing it")
+	taskThreads := flag.Int("task-threads", 0, "cap each task's runtime at `N` threads via GOMAXPROCS and friends (0 leaves them alone)")
+	excludeStartup := flag.Bool("exclude-startup", false, "time only the task body where the language can report it, not process startup")
+	measureMemory := flag.Bool("measure-memory", false, "record each task's peak RSS and mix it into the hash (unix only)")
+	isolate := flag.Bool("isolate", false, "pin each task to its own CPU with taskset (Linux only)")
+	affinityRotate := flag.Bool("affinity-rotate", false, "pin each --iterations run of a task to the next CPU in turn with taskset (Linux only)")
+	parallel := flag.Int("parallel", 0, "max languages compiling or running at once (0 = no limit)")
+	jsonOut := flag.Bool("json", false, "print the result as JSON")
+	stats := flag.Bool("stats", false, "print entropy accounting for the seed")
+	cppCompilersStr := flag.String("cpp-compilers", "", "comma-separated `compilers` (gcc, clang) to build the cpp task with, each timed as its own cpp-<compiler> language")
+	cppThreads := flag.Bool("cpp-threads", false, "sort with std::execution::par in the cpp task, so its timing depends on multicore scheduling")
+	fileMode := flag.String("file-mode", "", "octal permissions for the task sources and binaries written to the temp directory, like 0600 (default: 0644 sources, compiler-default binaries)")
+	bundle := flag.String("bundle", "", "also write the result, every seed format, the environment, the flags and a timestamp to `file` as one JSON document")
+	metricsFormat := flag.String("metrics-format", "", "with --http, also serve /metrics in this `format`; prometheus is the only one")
+	httpAddr := flag.String("http", "", "with --stream, serve /healthz and /seed on `addr`, like :8080")
+	dropOutliersFlag := flag.Bool("drop-outliers", false, "with --iterations, leave each language's fastest and slowest --outlier-percent of runs out before summing and hashing them")
+	outlierPercent := flag.Int("outlier-percent", 10, "the `PCT` of runs --drop-outliers drops from each end, 1-49")
+	fold := flag.String("fold", "aggregate", "what --iterations puts in the hash: aggregate (each language's total) or all (the total and every run)")
+	printCmd := flag.Bool("print-command", false, "print every compile and run command to stderr, shell-quoted, before running it")
+	fallbackChaosFlag := flag.Bool("fallback-chaos", false, "if --chaos high is missing tools that low doesn't need, run at low instead of failing preflight")
+	template := flag.String("template", "", "shape of the seed line, with {seed}, {bits}, {chaos} and {hash} placeholders")
+	collectStderr := flag.Bool("collect-stderr", false, "capture each task's stderr and mix its length and digest into the hash")
+	noInterpreted := flag.Bool("no-interpreted", false, "leave out the interpreted tasks and time only the compiled ones")
+	envVar := flag.String("env-var", "", "with --exec, also pass the seed in --seed-format to the command as environment variable `NAME`")
+	snapshotTimings := flag.String("snapshot-timings", "", "replay the --json result in `file` if it exists; otherwise measure and save the result there")
+	execCmd := flag.String("exec", "", "after the seed, run `command` with {seed}, {hex} and {raw} substituted, and exit with its status")
+	hashRounds := flag.Int("hash-rounds", 1, "hash the buffer once, then re-hash the digest until it's been hashed `N` times, to slow down brute force")
+	container := flag.String("container", "", "run every task inside a throwaway container from `image` with docker or podman")
+	require := flag.String("require", "", "minimum tool `versions`, like node>=18,python>=3.10,rustc>=1.70")
+	timingsOnly := flag.Bool("timings-only", false, "measure and print the timings only, without hashing or deriving a seed")
+	tmpdir := flag.String("tmpdir", "", "`dir` to write and run task files in (default system temp)")
+	compare := flag.String("compare", "", "print timing changes against a saved --json `file`")
+	randImpl := flag.String("rand-impl", "v1", "PRNG behind --verify, --permute and --emit-bytes: v1 (math/rand, 64-bit seed) or v2 (math/rand/v2 ChaCha8, 256-bit)")
+	emitBytes := flag.Int("emit-bytes", 0, "write `N` bytes from the seeded PRNG to stdout or --output")
+	output := flag.String("output", "", "write the raw seed bytes (or --emit-bytes output) to `file`")
+	quiet := flag.Bool("quiet", false, "don't print the seed line; diagnostics go to stderr")
+	maxRuntime := flag.Duration("max-runtime", 0, "stop running tasks once this `duration` is used up (0 = no limit)")
+	timeoutLang := flag.String("timeout-lang", "", "comma-separated `name=duration` limits: a language's name bounds each of its runs, a compiler's (rustc, g++ and so on) each of its compiles, in place of --compile-timeout")
+	compileTimeout := flag.Duration("compile-timeout", 0, "kill a compiler that hasn't finished after this `duration` (0 = no limit)")
+	streamEvery := flag.Duration("stream", 0, "compile once, then print a fresh seed every `interval` until interrupted")
+	minLangs := flag.Int("min-langs", 1, "fewest languages that must finish for a seed to be derived")
+	dumpBufferPath := flag.String("dump-buffer", "", "write the exact bytes that get hashed into the seed to `path`")
+	dumpBufferOnly := flag.Bool("dump-buffer-only", false, "with --dump-buffer, write the buffer and print nothing else")
+	retryMeasure := flag.Int("retry-measure", 0, "let failed tasks drop out while --min-langs finish, re-running them up to `N` rounds when too few did")
+	retries := flag.Int("retries", 0, "retry a failed compile or run up to `N` times with backoff")
+	seedCount := flag.Int("seed-count", 1, "derive `N` independent seeds from one measurement")
+	mixExitCodes := flag.Bool("mix-exit-codes", false, "hash each task's exit code alongside its timing")
+	key := flag.String("key", "", "blake2b key for domain separation (up to 64 bytes)")
+	salt := flag.String("salt", "", "application `salt` written at the start of the hash buffer")
+	pool := flag.String("pool", "", "mix the tail of the entropy pool `file` into the hash and append the seed to it")
 
+	flag.Usage = usage
 	flag.Parse()
+	if *showVersion {
+		fmt.Printf("PTRSG %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		os.Exit(0)
+	}
 
-	if *seed < 1 || *seed > 512 {
-		fmt.Fprintln(os.Stderr, "--seed must be 1-512")
+	// Commands come first but flags may follow them, so parse the rest again.
+	var command string
+	replayFile := ""
+	switch flag.Arg(0) {
+	case "selftest":
+		command = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+	case "replay":
+		if flag.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "replay needs a --json result file")
+			os.Exit(1)
+		}
+		command, replayFile = flag.Arg(0), flag.Arg(1)
+		flag.CommandLine.Parse(flag.Args()[2:])
+	}
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", strings.Join(flag.Args(), " "))
 		os.Exit(1)
 	}
 
-	if *chaos != "low" && *chaos != "high" {
-		fmt.Fprintln(os.Stderr, "--chaos must be low or high")
-		os.Exit(1)
+	// --seed-bytes is -S in bytes, and from here on only -S is looked at.
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if setFlags["seed-bytes"] {
+		if setFlags["S"] {
+			fmt.Fprintln(os.Stderr, "-S and --seed-bytes both set the seed length; pick one")
+			os.Exit(1)
+		}
+		if *seedBytes < 1 || *seedBytes > hashBits/8 {
+			fmt.Fprintf(os.Stderr, "--seed-bytes must be 1-%d, blake2b's output in bytes\n", hashBits/8)
+			os.Exit(1)
+		}
+		*seed = *seedBytes * 8
 	}
 
-	return verbosity, *queue, *chaos, *seed
-}
+	if !slices.Contains(bufferLayouts, *bufferLayout) {
+		fmt.Fprintf(os.Stderr, "--buffer-layout must be one of %s\n", strings.Join(bufferLayouts, ", "))
+		os.Exit(1)
+	}
+	if command == "replay" && !setFlags["buffer-layout"] {
+		// Left for replay to take from the file.
+		*bufferLayout = ""
+	}
 
-// preflightLangCheck prints version info for each required tool
-// and exits if any are missing.
-func preflightLangCheck(v Verbosity) {
-	tools := []struct {
-		name  string
-		flags []string
+	// Lower bounds for the integer flags, checked before anything else
+	// looks at them. Bounds that depend on other flags, like -S against
+	// the hash size, come with the checks for those flags further down.
+	for _, f := range []struct {
+		name     string
+		val, min int
 	}{
-		{"lua", []string{"-v"}},
-		{"python", []string{"--version"}},
-		{"node", []string{"--version"}},
-		{"g++", []string{"--version"}},
-		{"rustc", []string{"--version"}},
+		{"-S", *seed, 1},
+		{"--iterations", *iterations, 1},
+		{"--parallel", *parallel, 0},
+		{"--task-threads", *taskThreads, 0},
+		{"--stress", *stress, 0},
+		{"--retries", *retries, 0},
+		{"--retry-measure", *retryMeasure, 0},
+		{"--min-langs", *minLangs, 1},
+		{"--seed-count", *seedCount, 1},
+		{"--hash-rounds", *hashRounds, 1},
+		{"--assert-attempts", *assertAttempts, 1},
+		{"--verify", *verify, 0},
+		{"--permute", *permute, 0},
+		{"--emit-bytes", *emitBytes, 0},
+	} {
+		if f.val >= f.min {
+			continue
+		}
+		if f.min == 0 {
+			fmt.Fprintf(os.Stderr, "%s must not be negative\n", f.name)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s must be at least %d\n", f.name, f.min)
+		}
+		os.Exit(1)
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	missing := []string{}
-
-	for _, t := range tools {
-		wg.Add(1)
-		go func(name string, flags []string) {
-			defer wg.Done()
-			cmd := exec.Command(name, flags...)
-			out, err := cmd.CombinedOutput()
-			if v == VerbosityHeavy {
-				fmt.Printf("[DEBUG] %s %s → ", name, strings.Join(flags, " "))
-				if err != nil {
-					fmt.Printf("error: %v\n", err)
-				} else {
-					fmt.Printf(strings.TrimSpace(string(out)) + "\n")
-				}
-			}
-			if err != nil {
-				mu.Lock()
-				missing = append(missing, name)
-				mu.Unlock()
-			}
-		}(t.name, t.flags)
+	if *explainChaosLevel != "" && *explainChaosLevel != "low" && *explainChaosLevel != "high" {
+		fmt.Fprintln(os.Stderr, "--explain-chaos must be low or high")
+		os.Exit(1)
 	}
-	wg.Wait()
-
-	if len(missing) > 0 {
-		fmt.Printf("Preflight check failed: %s missing!\n", strings.Join(missing, ", "))
+	if *dumpSrc && command != "" {
+		fmt.Fprintf(os.Stderr, "--dump-sources doesn't work with %s\n", command)
 		os.Exit(1)
 	}
 
-	if v == VerbosityHeavy {
-		fmt.Println("[DEBUG] Preflight check passed: all required tools are available")
+	if _, ok := timeUnits[*timeUnit]; !ok {
+		fmt.Fprintln(os.Stderr, "--time-unit must be ns, us or ms")
+		os.Exit(1)
 	}
-}
 
-var codeMap = map[string]string{
-	"lua": `local t = {}
-for i = 1, 100000 do
-    t[i] = tostring(i) .. i
-end
-table.sort(t)
-`,
-	"python": `lst = [str(i) + str(i*i) for i in range(100000)]
-lst.sort()
-`,
-	"node": `let arr = Array.from({length: 100000}, (_, i) => '' + i + (i*i));
-arr.sort();
-`,
-}
-
-func writeFiles(tmpdir string, langs []string) (map[string]string, error) {
-	paths := make(map[string]string)
-	for _, lang := range langs {
-		ext := map[string]string{
-			"lua":    "lua",
-			"python": "py",
-			"node":   "js",
-		}[lang]
-		fname := fmt.Sprintf("task.%s", ext)
-		path := filepath.Join(tmpdir, fname)
-		if err := os.WriteFile(path, []byte(codeMap[lang]), 0644); err != nil {
-			return nil, err
+	var mode os.FileMode
+	if *fileMode != "" {
+		m, err := strconv.ParseUint(*fileMode, 8, 32)
+		if err != nil || m > 0777 || m&0600 != 0600 {
+			fmt.Fprintln(os.Stderr, "--file-mode must be octal permissions the owner can read and write, like 0600")
+			os.Exit(1)
 		}
-		paths[lang] = path
+		mode = os.FileMode(m)
 	}
-	return paths, nil
-}
 
-func compileCpp(path string, v Verbosity) (string, error) {
-	dir := filepath.Dir(path)
-	exe := filepath.Join(dir, "task_cpp.exe")
-	cmd := exec.Command("g++", "-O0", path, "-o", exe)
-	if v == VerbosityHeavy {
-		fmt.Printf("[DEBUG] gcc compile: %v\n", cmd.Args)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	execArgs := strings.Fields(*execCmd)
+	if *execCmd != "" && len(execArgs) == 0 {
+		fmt.Fprintln(os.Stderr, "--exec needs a command")
+		os.Exit(1)
 	}
-	return exe, cmd.Run()
-}
-
-func compileGoFile(path string, v Verbosity) (string, error) {
-	dir := filepath.Dir(path)
-	exe := filepath.Join(dir, "task_go.exe")
-	cmd := exec.Command("go", "build", "-o", exe, path)
-	if v == VerbosityHeavy {
-		fmt.Printf("[DEBUG] go build: %v\n", cmd.Args)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	if len(execArgs) > 0 && (*streamEvery > 0 || *timingsOnly || command == "selftest") {
+		fmt.Fprintln(os.Stderr, "--exec can't be combined with --stream, --timings-only or selftest")
+		os.Exit(1)
 	}
-	return exe, cmd.Run()
-}
-
-func compileRust(path string, v Verbosity) (string, error) {
-	dir := filepath.Dir(path)
-	exe := filepath.Join(dir, "task_rust.exe")
-	cmd := exec.Command("rustc", "-C", "opt-level=0", path, "-o", exe)
-	if v == VerbosityHeavy {
-		fmt.Printf("[DEBUG] rustc compile: %v\n", cmd.Args)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	if *envVar != "" && len(execArgs) == 0 {
+		fmt.Fprintln(os.Stderr, "--env-var only works with --exec")
+		os.Exit(1)
 	}
-	return exe, cmd.Run()
-}
-
-func writeAndCompileExtra(tmpdir, chaos string, v Verbosity) (map[string]string, error) {
-	extraCodes := map[string]struct {
-		code string
-		comp func(string, Verbosity) (string, error)
-	}{
-		"cpp": {
-			code: `#include <iostream>
-#include <vector>
-#include <string>
-#include <algorithm>
-#include <sstream>
-int main() {
-    std::vector<std::string> v;
-    v.reserve(100000);
-    for (int i = 0; i < 100000; ++i) {
-        std::ostringstream oss;
-        oss << i << i*i;
-        v.push_back(oss.str());
-    }
-    std::sort(v.begin(), v.end());
-    return 0;
-}
-`,
-			comp: compileCpp,
-		},
-		"go": {
-			code: `package main
-import (
-    "sort"
-    "strconv"
-)
-func main() {
-    s := make([]string, 100000)
-    for i := 0; i < 100000; i++ {
-        s[i] = strconv.Itoa(i) + strconv.Itoa(i*i)
-    }
-    sort.Strings(s)
-}
-`,
-			comp: compileGoFile,
-		},
-		"rust": {
-			code: `fn main() {
-    let mut v: Vec<String> = (0u64..100_000)
-        .map(|i| format!("{}{}", i, i * i))
-        .collect();
-    v.sort();
-}
-`,
-			comp: compileRust,
-		},
+	if strings.ContainsAny(*envVar, "=\x00") {
+		fmt.Fprintln(os.Stderr, "--env-var must be a variable name, without = or NUL")
+		os.Exit(1)
 	}
 
-	langs := []string{"go"}
-	if chaos == "high" {
-		langs = []string{"go", "cpp", "rust"}
+	if *snapshotTimings != "" && (command != "" || *streamEvery > 0 || *timingsOnly || *compileOnlyDir != "" || *verifyRepro) {
+		fmt.Fprintln(os.Stderr, "--snapshot-timings can't be combined with selftest, replay, --stream, --timings-only, --compile-only or --verify-reproducible")
+		os.Exit(1)
 	}
 
-	result := make(map[string]string)
-	for _, lang := range langs {
-		ext := map[string]string{"cpp": "cpp", "go": "go", "rust": "rs"}[lang]
-		path := filepath.Join(tmpdir, fmt.Sprintf("task.%s", ext))
-		if err := os.WriteFile(path, []byte(extraCodes[lang].code), 0644); err != nil {
-			return nil, err
-		}
-		exe, err := extraCodes[lang].comp(path, v)
-		if err != nil {
-			return nil, err
-		}
-		result[lang] = exe
+	if *bundle != "" && (command == "selftest" || *streamEvery > 0 || *timingsOnly) {
+		fmt.Fprintln(os.Stderr, "--bundle can't be combined with selftest, --stream or --timings-only")
+		os.Exit(1)
 	}
-	return result, nil
-}
 
-func timeRun(cmdArgs []string, v Verbosity) (int64, error) {
-	if v == VerbosityHeavy {
-		fmt.Printf("[DEBUG] Running: %v\n", cmdArgs)
+	if *httpAddr != "" && *streamEvery <= 0 {
+		fmt.Fprintln(os.Stderr, "--http needs --stream")
+		os.Exit(1)
 	}
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-	if v == VerbosityHeavy {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	if *metricsFormat != "" && *metricsFormat != "prometheus" {
+		fmt.Fprintln(os.Stderr, "--metrics-format must be prometheus")
+		os.Exit(1)
+	}
+	if *metricsFormat != "" && *httpAddr == "" {
+		fmt.Fprintln(os.Stderr, "--metrics-format needs --http")
+		os.Exit(1)
 	}
-	start := time.Now()
-	err := cmd.Run()
-	return time.Since(start).Nanoseconds(), err
-}
 
-func main() {
-	verbosity, queue, chaos, seedVal := parseFlags()
-	preflightLangCheck(verbosity)
+	if *fold != "aggregate" && *fold != "all" {
+		fmt.Fprintln(os.Stderr, "--fold must be aggregate or all")
+		os.Exit(1)
+	}
+	if *fold == "all" && *iterations < 2 && command != "replay" {
+		fmt.Fprintln(os.Stderr, "--fold all needs --iterations 2 or more")
+		os.Exit(1)
+	}
 
-	if verbosity >= VerbosityLite {
-		fmt.Printf("PTRSG %s\n", version)
-		fmt.Printf("Using chaos=%s, queue=%v\n", chaos, queue)
+	if *outlierPercent < 1 || *outlierPercent > 49 {
+		fmt.Fprintln(os.Stderr, "--outlier-percent must be 1-49")
+		os.Exit(1)
+	}
+	// Fewer runs than this and rounding down would leave none out.
+	if need := (100 + *outlierPercent - 1) / *outlierPercent; *dropOutliersFlag && *iterations < need && command != "replay" {
+		fmt.Fprintf(os.Stderr, "--drop-outliers with --outlier-percent %d needs --iterations %d or more\n", *outlierPercent, need)
+		os.Exit(1)
+	}
+	var dropPercent int
+	if *dropOutliersFlag {
+		dropPercent = *outlierPercent
 	}
 
-	tmpdir, err := os.MkdirTemp("", "prandom_")
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if *cppThreads && *workload != "sort" {
+		fmt.Fprintln(os.Stderr, "--cpp-threads only has a parallel version of --workload sort")
 		os.Exit(1)
 	}
-	defer os.RemoveAll(tmpdir)
 
-	if verbosity >= VerbosityLite {
-		fmt.Printf("Preparing files in %s...\n", tmpdir)
+	var containerRuntime string
+	if *container != "" {
+		var conflicts []string
+		for name, set := range map[string]bool{
+			"--isolate": *isolate, "--affinity-rotate": *affinityRotate, "--perf": *perf,
+			"--measure-memory": *measureMemory, "--mix-ctxsw": *mixCtxsw,
+			"--sample-clock process-cpu":   *sampleClock == "process-cpu",
+			"--concurrency-model pipeline": *concurrencyModel == "pipeline",
+		} {
+			if set {
+				conflicts = append(conflicts, name)
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			fmt.Fprintf(os.Stderr, "--container can't be combined with %s, which would only see the container client\n", strings.Join(conflicts, ", "))
+			os.Exit(1)
+		}
+		path, err := findContainerRuntime()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		containerRuntime = path
+		// The bind mount needs an absolute path.
+		if *tmpdir != "" {
+			if abs, err := filepath.Abs(*tmpdir); err == nil {
+				*tmpdir = abs
+			}
+		}
 	}
 
-	langs := []string{"lua", "python", "node"}
-	paths, err := writeFiles(tmpdir, langs)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	if *beacon != "" {
+		if u, err := url.Parse(*beacon); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "--beacon must be an http or https URL, got %q\n", *beacon)
+			os.Exit(1)
+		}
+	}
+	if *beaconOnError != "fail" && *beaconOnError != "warn" {
+		fmt.Fprintln(os.Stderr, "--beacon-on-error must be fail or warn")
 		os.Exit(1)
 	}
 
-	extra, err := writeAndCompileExtra(tmpdir, chaos, verbosity)
+	requirements, err := parseRequirements(*require)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	procMap := make(map[string][]string)
-	for lang, p := range paths {
-		procMap[lang] = []string{lang, p}
+	if *timingsOnly {
+		var needSeed []string
+		for name, set := range map[string]bool{
+			"--raw-hash": *rawHash, "--sweep": *sweep, "--stats": *stats,
+			"--emit-bytes": *emitBytes > 0, "--output": *output != "",
+			"--verify": *verify > 0, "--permute": *permute > 0,
+			"--seed-count": *seedCount > 1, "--pool": *pool != "",
+			"--dump-buffer": *dumpBufferPath != "", "--assert-bits": *assertBits > 0,
+			"--mix-os-entropy": *mixOSEntropy, "--beacon": *beacon != "",
+			"--buffer-layout": setFlags["buffer-layout"],
+		} {
+			if set {
+				needSeed = append(needSeed, name)
+			}
+		}
+		if command != "" {
+			needSeed = append(needSeed, command)
+		}
+		if len(needSeed) > 0 {
+			sort.Strings(needSeed)
+			fmt.Fprintf(os.Stderr, "--timings-only can't be combined with %s, which need a seed\n", strings.Join(needSeed, ", "))
+			os.Exit(1)
+		}
 	}
-	for lang, exe := range extra {
-		procMap[lang] = []string{exe}
+
+	if *strict && (*bestEffort || *beaconOnError == "warn") {
+		fmt.Fprintln(os.Stderr, "--strict can't be combined with --best-effort or --beacon-on-error warn, which ask to carry on past the warnings it fails on")
+		os.Exit(1)
 	}
 
-	timings := make(map[string]int64)
-	if queue {
-		for lang, cmdArgs := range procMap {
-			if verbosity >= VerbosityLite {
-				fmt.Printf("Running %s...\n", lang)
-			}
-			t, err := timeRun(cmdArgs, verbosity)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
+	if *commit != "" {
+		var leaks []string
+		for name, set := range map[string]bool{
+			"--raw-hash": *rawHash, "--sweep": *sweep, "--json": *jsonOut,
+			"--emit-bytes": *emitBytes > 0, "--output": *output != "",
+			"--verify": *verify > 0, "--permute": *permute > 0,
+			"--seed-count": *seedCount > 1, "--exec": *execCmd != "",
+			"--bundle": *bundle != "", "--template": *template != "",
+			"--stream": *streamEvery > 0, "--timings-only": *timingsOnly,
+			"--reveal": *revealFile != "", "--pool": *pool != "",
+			"--snapshot-timings": *snapshotTimings != "", "--dump-buffer": *dumpBufferPath != "",
+		} {
+			if set {
+				leaks = append(leaks, name)
 			}
-			timings[lang] = t
 		}
-	} else {
-		var wg2 sync.WaitGroup
-		var mu2 sync.Mutex
-		for lang, cmdArgs := range procMap {
-			wg2.Add(1)
-			go func(l string, args []string) {
-				defer wg2.Done()
-				t, err := timeRun(args, verbosity)
-				if err != nil {
-					fmt.Fprintln(os.Stderr, err)
-					os.Exit(1)
-				}
-				mu2.Lock()
-				timings[l] = t
-				mu2.Unlock()
-			}(lang, cmdArgs)
+		if command == "selftest" {
+			leaks = append(leaks, command)
 		}
-		wg2.Wait()
-	}
-
-	if verbosity >= VerbosityLite {
-		fmt.Println("Timings (ns):")
-		keys := make([]string, 0, len(timings))
-		for k := range timings {
-			keys = append(keys, k)
+		if len(leaks) > 0 {
+			sort.Strings(leaks)
+			fmt.Fprintf(os.Stderr, "--commit can't be combined with %s, which would give the seed away\n", strings.Join(leaks, ", "))
+			os.Exit(1)
 		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			fmt.Printf("  %s: %d\n", k, timings[k])
+	}
+	if *commit != "" {
+		// Checked up front too, so a full run isn't wasted on it.
+		if _, err := os.Stat(*commit); err == nil {
+			fmt.Fprintf(os.Stderr, "--commit: %s already exists, reveal or move it first\n", *commit)
+			os.Exit(1)
 		}
 	}
-
-	buf := new(bytes.Buffer)
-	for _, t := range timings {
-		var b [8]byte
-		binary.BigEndian.PutUint64(b[:], uint64(t))
-		buf.Write(b[:])
+	if *revealFile != "" && (command != "" || *streamEvery > 0) {
+		fmt.Fprintln(os.Stderr, "--reveal can't be combined with selftest, replay or --stream")
+		os.Exit(1)
 	}
-
-	hash := blake2b.Sum512(buf.Bytes())
-
-	if verbosity == VerbosityHeavy {
-		fmt.Printf("[DEBUG] Full Blake2b: %x\n", hash)
+	if *commitment != "" && *revealFile == "" {
+		fmt.Fprintln(os.Stderr, "--commitment only works with --reveal")
+		os.Exit(1)
 	}
 
-	byteLen := (seedVal + 7) / 8
-	raw := hash[:byteLen]
-	if seedVal%8 != 0 {
-		raw[0] >>= (8 - (seedVal % 8))
+	if *freeze && (!*jsonOut || command == "replay") {
+		fmt.Fprintln(os.Stderr, "--freeze needs --json and doesn't work with replay")
+		os.Exit(1)
 	}
 
-	seedInt := new(big.Int).SetBytes(raw)
-	fmt.Printf("Seed generated (%d-bit): %s\n", seedVal, seedInt)
-	_ = rand.New(rand.NewSource(seedInt.Int64()))
+	if *compileOnlyDir != "" && command != "" {
+		fmt.Fprintf(os.Stderr, "--compile-only doesn't work with %s\n", command)
+		os.Exit(1)
+	}
+
+	if *checkCompileFlag && (command != "" || *compileOnlyDir != "" || *verifyRepro || *streamEvery > 0) {
+		fmt.Fprintln(os.Stderr, "--check-compile doesn't work with selftest, replay, --compile-only, --verify-reproducible or --stream")
+		os.Exit(1)
+	}
+
+	if *verifyRepro && (command != "" || *compileOnlyDir != "") {
+		fmt.Fprintln(os.Stderr, "--verify-reproducible doesn't work with selftest, replay or --compile-only")
+		os.Exit(1)
+	}
+
+	if *seed > hashBits {
+		fmt.Fprintf(os.Stderr, "-S %d exceeds blake2b's %d-bit output\n", *seed, hashBits)
+		os.Exit(1)
+	}
+
+	if *chaos != "low" && *chaos != "high" {
+		fmt.Fprintln(os.Stderr, "--chaos must be low or high")
+		os.Exit(1)
+	}
+
+	if *goCompiler != "go" && *goCompiler != "tinygo" {
+		fmt.Fprintln(os.Stderr, "--go-compiler must be go or tinygo")
+		os.Exit(1)
+	}
+
+	if *goCompiler == "tinygo" && *gcflags != "" {
+		fmt.Fprintln(os.Stderr, "--gcflags only works with --go-compiler go")
+		os.Exit(1)
+	}
+
+	if *maxRuntime < 0 {
+		fmt.Fprintln(os.Stderr, "--max-runtime must not be negative")
+		os.Exit(1)
+	}
+	if *compileTimeout < 0 {
+		fmt.Fprintln(os.Stderr, "--compile-timeout must not be negative")
+		os.Exit(1)
+	}
+	if *streamEvery < 0 {
+		fmt.Fprintln(os.Stderr, "--stream must not be negative")
+		os.Exit(1)
+	}
+	if *streamEvery > 0 && (command != "" || *compileOnlyDir != "" || *verifyRepro) {
+		fmt.Fprintln(os.Stderr, "--stream doesn't work with selftest, replay, --compile-only or --verify-reproducible")
+		os.Exit(1)
+	}
+
+	if *dumpBufferOnly && *dumpBufferPath == "" {
+		fmt.Fprintln(os.Stderr, "--dump-buffer-only needs --dump-buffer")
+		os.Exit(1)
+	}
+
+	if len(*key) > blake2b.Size {
+		fmt.Fprintf(os.Stderr, "--key must be at most %d bytes\n", blake2b.Size)
+		os.Exit(1)
+	}
+
+	if !slices.Contains(randImpls, *randImpl) {
+		fmt.Fprintf(os.Stderr, "--rand-impl must be one of %s\n", strings.Join(randImpls, ", "))
+		os.Exit(1)
+	}
+
+	if bits := prngBits(*randImpl); *emitBytes > 0 && *seed > bits {
+		warnf("-S %d is wider than the %d bits the PRNG is seeded with; --emit-bytes output carries at most %d bits of seed", *seed, bits, bits)
+	}
+
+	if !slices.Contains(seedFormats, *seedFormat) {
+		fmt.Fprintf(os.Stderr, "--seed-format must be one of %s\n", strings.Join(seedFormats, ", "))
+		os.Exit(1)
+	}
+
+	if *hexPrefix && *seedFormat != "hex" {
+		fmt.Fprintln(os.Stderr, "--hex-prefix needs --seed-format hex")
+		os.Exit(1)
+	}
+	if *seedFormat == "uuid" && *seed < 128 && *revealFile == "" {
+		fmt.Fprintln(os.Stderr, "--seed-format uuid needs -S 128 or more")
+		os.Exit(1)
+	}
+
+	if *maxLoad < 0 {
+		fmt.Fprintln(os.Stderr, "--max-load must not be negative")
+		os.Exit(1)
+	}
+
+	if *waitForLoad && *maxLoad == 0 {
+		fmt.Fprintln(os.Stderr, "--wait-for-load needs --max-load")
+		os.Exit(1)
+	}
+
+	var extraLangs []string
+	for _, lang := range strings.Split(*extraLangsStr, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || slices.Contains(extraLangs, lang) {
+			continue
+		}
+		if !slices.Contains(optionalLangs, lang) {
+			fmt.Fprintf(os.Stderr, "--extra-langs must only name %s, got %q\n", strings.Join(optionalLangs, ", "), lang)
+			os.Exit(1)
+		}
+		extraLangs = append(extraLangs, lang)
+	}
+
+	var cppCompilers []string
+	for _, c := range strings.Split(*cppCompilersStr, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" || slices.Contains(cppCompilers, c) {
+			continue
+		}
+		if cppCompilerTools[c] == "" {
+			fmt.Fprintf(os.Stderr, "--cpp-compilers must only name gcc or clang, got %q\n", c)
+			os.Exit(1)
+		}
+		cppCompilers = append(cppCompilers, c)
+	}
+
+	extraCmds := make(map[string][]string)
+	for _, spec := range extraCmdFlags {
+		name, command, _ := strings.Cut(spec, "=")
+		args := strings.Fields(command)
+		switch {
+		case !manifestNameRe.MatchString(name):
+			fmt.Fprintf(os.Stderr, "--extra-cmd name %q must be lowercase letters, digits, - or _\n", name)
+			os.Exit(1)
+		case slices.Contains(builtinLangs, name), cppVariant(name) != "":
+			fmt.Fprintf(os.Stderr, "--extra-cmd %s clashes with a built-in language\n", name)
+			os.Exit(1)
+		case extraCmds[name] != nil:
+			fmt.Fprintf(os.Stderr, "--extra-cmd %s is given twice\n", name)
+			os.Exit(1)
+		case len(args) == 0:
+			fmt.Fprintf(os.Stderr, "--extra-cmd %s has no command\n", name)
+			os.Exit(1)
+		}
+		extraCmds[name] = args
+	}
+
+	langTimeouts := make(map[string]time.Duration)
+	for _, spec := range strings.Split(*timeoutLang, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		name, v, _ := strings.Cut(spec, "=")
+		d, err := time.ParseDuration(v)
+		switch {
+		case name == "" || err != nil:
+			fmt.Fprintf(os.Stderr, "--timeout-lang %q must look like name=duration\n", spec)
+			os.Exit(1)
+		case d <= 0:
+			fmt.Fprintf(os.Stderr, "--timeout-lang %s must be positive\n", name)
+			os.Exit(1)
+		case langTimeouts[name] != 0:
+			fmt.Fprintf(os.Stderr, "--timeout-lang %s is given twice\n", name)
+			os.Exit(1)
+		}
+		langTimeouts[name] = d
+	}
+
+	weights := make(map[string]int)
+	for _, spec := range weightFlags {
+		name, n, _ := strings.Cut(spec, "=")
+		w, err := strconv.Atoi(n)
+		switch {
+		case name == "" || err != nil:
+			fmt.Fprintf(os.Stderr, "--weight %q must look like lang=N\n", spec)
+			os.Exit(1)
+		case w < 1 || w > 100:
+			fmt.Fprintf(os.Stderr, "--weight %s must be 1-100\n", name)
+			os.Exit(1)
+		case weights[name] != 0:
+			fmt.Fprintf(os.Stderr, "--weight %s is given twice\n", name)
+			os.Exit(1)
+		}
+		weights[name] = w
+	}
+
+	binaries := make(map[string]string)
+	for tool, v := range toolFlagValues {
+		if *v == "" {
+			fmt.Fprintf(os.Stderr, "the %s binary must not be empty\n", tool)
+			os.Exit(1)
+		}
+		if *v != tool {
+			binaries[tool] = *v
+		}
+	}
+
+	perfEvent := ""
+	if *perf {
+		if _, err := exec.LookPath("perf"); err != nil {
+			warnf("perf isn't available, --perf falls back to wall time only")
+		} else {
+			perfEvent = *perfEventFlag
+		}
+	}
+
+	switch *concurrencyModel {
+	case "simple":
+	case "pipeline":
+		if *prime || *isolate {
+			fmt.Fprintln(os.Stderr, "--concurrency-model pipeline can't be combined with --prime-binaries or --isolate")
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "--concurrency-model must be simple or pipeline")
+		os.Exit(1)
+	}
+
+	if *histogram && *iterations < 2 {
+		fmt.Fprintln(os.Stderr, "--histogram needs --iterations 2 or more")
+		os.Exit(1)
+	}
+
+	if *assertBits < 0 || *assertBits > *seed {
+		fmt.Fprintln(os.Stderr, "--assert-bits must be between 0 and -S")
+		os.Exit(1)
+	}
+
+	if *persistent && *mixASLR {
+		fmt.Fprintln(os.Stderr, "--mix-aslr can't be combined with --persistent, whose drivers own stdout")
+		os.Exit(1)
+	}
+	if *persistent && (*excludeStartup || *precompileFlag || *perf) {
+		fmt.Fprintln(os.Stderr, "--persistent doesn't work with --exclude-startup, --precompile or --perf")
+		os.Exit(1)
+	}
+	if *sampleClock != "monotonic" && *sampleClock != "process-cpu" {
+		fmt.Fprintln(os.Stderr, "--sample-clock must be monotonic or process-cpu")
+		os.Exit(1)
+	}
+	if *sampleClock == "process-cpu" && (*excludeStartup || *persistent) {
+		fmt.Fprintln(os.Stderr, "--sample-clock process-cpu doesn't work with --exclude-startup or --persistent")
+		os.Exit(1)
+	}
+	if *endian != "big" && *endian != "little" {
+		fmt.Fprintln(os.Stderr, "--endian must be big or little")
+		os.Exit(1)
+	}
+	if _, ok := timingPrecisions[*timingPrecision]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown --timing-precision %q: want ns, 10ns, 100ns or us\n", *timingPrecision)
+		os.Exit(1)
+	}
+	if *debias && len(weightFlags) > 0 {
+		fmt.Fprintln(os.Stderr, "--weight doesn't work with --debias")
+		os.Exit(1)
+	}
+	if *minSpread < 0 {
+		fmt.Fprintln(os.Stderr, "--min-spread must not be negative")
+		os.Exit(1)
+	}
+
+	if *color != "auto" && *color != "always" && *color != "never" {
+		fmt.Fprintln(os.Stderr, "--color must be auto, always or never")
+		os.Exit(1)
+	}
+
+	if *format != "plain" && *format != "table" {
+		fmt.Fprintln(os.Stderr, "--format must be plain or table")
+		os.Exit(1)
+	}
+
+	if *verify > 0 && *jsonOut {
+		fmt.Fprintln(os.Stderr, "--verify would print after the --json object on stdout, leaving it invalid JSON")
+		os.Exit(1)
+	}
+
+	if *permute > 0 && *jsonOut {
+		fmt.Fprintln(os.Stderr, "--permute would print after the --json object on stdout, leaving it invalid JSON")
+		os.Exit(1)
+	}
+	if *permute > 0 && *emitBytes > 0 && *output == "" {
+		fmt.Fprintln(os.Stderr, "--permute would mix with the --emit-bytes payload on stdout; add --output")
+		os.Exit(1)
+	}
+
+	if *sweep && (*rawHash || *jsonOut || *seedFormat == "uuid") {
+		fmt.Fprintln(os.Stderr, "--sweep can't be combined with --raw-hash, --json or --seed-format uuid")
+		os.Exit(1)
+	}
+
+	if *affinityRotate && *isolate {
+		fmt.Fprintln(os.Stderr, "--affinity-rotate can't be combined with --isolate")
+		os.Exit(1)
+	}
+	var tasksetPath string
+	if *affinityRotate {
+		tasksetPath = affinityTaskset()
+	}
+
+	if *measureMemory && !maxRSSSupported {
+		warnf("--measure-memory isn't supported on this platform, ignoring it")
+	}
+	if *mixCtxsw && !ctxSwitchesSupported {
+		warnf("--mix-ctxsw isn't supported on this platform, ignoring it")
+	}
+
+	if *rawHash && (*jsonOut || *emitBytes > 0 || *seedCount > 1) {
+		fmt.Fprintln(os.Stderr, "--raw-hash can't be combined with --json, --emit-bytes or --seed-count")
+		os.Exit(1)
+	}
+
+	if _, ok := codeMap[*workload]; !ok {
+		fmt.Fprintf(os.Stderr, "--workload must be one of %s\n", strings.Join(workloads, ", "))
+		os.Exit(1)
+	}
+
+	if *profileSummary && *profile == "" {
+		fmt.Fprintln(os.Stderr, "--profile-summary needs --profile path")
+		os.Exit(1)
+	}
+
+	nodeFlags := strings.Fields(*nodeFlagsStr)
+	for _, f := range nodeFlags {
+		if !strings.HasPrefix(f, "-") {
+			fmt.Fprintf(os.Stderr, "--node-flags must only contain flags, got %q\n", f)
+			os.Exit(1)
+		}
+	}
+
+	cppFlags := strings.Fields(*cflagsCpp)
+	rustFlags := strings.Fields(*cflagsRust)
+	if err := checkOutputFlags("--cflags-cpp", cppFlags, "-o"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := checkOutputFlags("--cflags-rust", rustFlags, "-o", "--out-dir", "--emit"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	return config{
+		verbosity:          verbosity,
+		queue:              *queue,
+		chaos:              *chaos,
+		seedBits:           *seed,
+		profile:            *profile,
+		profileSummary:     *profileSummary,
+		cppFlags:           cppFlags,
+		rustFlags:          rustFlags,
+		goGCFlags:          *gcflags,
+		parallel:           *parallel,
+		json:               *jsonOut,
+		stats:              *stats,
+		tmpdir:             *tmpdir,
+		compare:            *compare,
+		emitBytes:          *emitBytes,
+		output:             *output,
+		quiet:              *quiet || (*emitBytes > 0 && *output == "") || len(execArgs) > 0,
+		retries:            *retries,
+		pool:               *pool,
+		seedCount:          *seedCount,
+		goCompiler:         *goCompiler,
+		workload:           *workload,
+		mixExitCodes:       *mixExitCodes,
+		command:            command,
+		maxRuntime:         *maxRuntime,
+		compileTimeout:     *compileTimeout,
+		langTimeouts:       langTimeouts,
+		stream:             *streamEvery,
+		minLangs:           *minLangs,
+		salt:               []byte(*salt),
+		key:                []byte(*key),
+		compilerCheck:      *compilerCheck,
+		precompile:         *precompileFlag,
+		nodeFlags:          nodeFlags,
+		isolate:            *isolate,
+		affinityTaskset:    tasksetPath,
+		seedFormat:         *seedFormat,
+		hexPrefix:          *hexPrefix,
+		rawHash:            *rawHash,
+		taskThreads:        *taskThreads,
+		measureMemory:      *measureMemory,
+		excludeStartup:     *excludeStartup,
+		concurrencyModel:   *concurrencyModel,
+		maxLoad:            *maxLoad,
+		waitForLoad:        *waitForLoad,
+		dither:             *dither,
+		perfEvent:          perfEvent,
+		checkDeterminism:   checkDeterminism,
+		extraLangs:         extraLangs,
+		sweep:              *sweep,
+		verify:             *verify,
+		permute:            *permute,
+		timingsOnly:        *timingsOnly,
+		require:            requirements,
+		hashRounds:         *hashRounds,
+		exec:               execArgs,
+		envVar:             *envVar,
+		noInterpreted:      *noInterpreted,
+		collectStderr:      *collectStderr,
+		template:           *template,
+		fallbackChaos:      *fallbackChaosFlag,
+		printCommand:       *printCmd,
+		fold:               *fold,
+		dropOutliers:       dropPercent,
+		http:               *httpAddr,
+		metricsFormat:      *metricsFormat,
+		bundle:             *bundle,
+		cppThreads:         *cppThreads,
+		cppCompilers:       cppCompilers,
+		fileMode:           mode,
+		verifyRepro:        *verifyRepro,
+		randImpl:           *randImpl,
+		includeCompileTime: *includeCompileTime,
+		container:          *container,
+		containerRuntime:   containerRuntime,
+		manifestPath:       *manifestPath,
+		compileOnly:        *compileOnlyDir,
+		checkCompile:       *checkCompileFlag,
+		dumpSources:        *dumpSrc,
+		format:             *format,
+		mixOSEntropy:       *mixOSEntropy,
+		beacon:             *beacon,
+		beaconOnError:      *beaconOnError,
+		commit:             *commit,
+		reveal:             *revealFile,
+		commitment:         *commitment,
+		replayFile:         replayFile,
+		snapshotTimings:    *snapshotTimings,
+		failFast:           *failFast && !*bestEffort,
+		bestEffort:         *bestEffort,
+		extraCmds:          extraCmds,
+		weights:            weights,
+		toolBinaries:       binaries,
+		color:              *color,
+		assertBits:         *assertBits,
+		assertAttempts:     *assertAttempts,
+		minSpread:          *minSpread,
+		debias:             *debias,
+		timingPrecision:    timingPrecisions[*timingPrecision],
+		bufferLayout:       *bufferLayout,
+		persistent:         *persistent,
+		endian:             *endian,
+		sampleClock:        *sampleClock,
+		reorderGuard:       *reorderGuardFlag,
+		benchBaseline:      *benchBaseline,
+		mixBinsize:         *mixBinsize,
+		mixCtxsw:           *mixCtxsw && ctxSwitchesSupported,
+		mixASLR:            *mixASLR,
+		strict:             *strict,
+		freeze:             *freeze,
+		timeUnit:           *timeUnit,
+		stress:             *stress,
+		explainChaos:       *explainChaosLevel,
+		retryMeasure:       *retryMeasure,
+		dumpBuffer:         *dumpBufferPath,
+		dumpBufferOnly:     *dumpBufferOnly,
+		iterations:         *iterations,
+		histogram:          *histogram,
+		primeBinaries:      *prime,
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "PTRSG %s\n\nUsage: %s [flags] [selftest | replay FILE]\n\n", version, filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "  --verbose [none|lite|heavy]")
+	fmt.Fprintln(out, "    \tlogging output. none (default) prints only the seed, lite adds")
+	fmt.Fprintln(out, "    \tuseful info, heavy logs everything. A bare --verbose means heavy")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  selftest     run the pipeline a few times and check every seed differs")
+	fmt.Fprintln(out, "  replay FILE  rederive the seed from a --json result without measuring")
+	fmt.Fprintln(out, "\nChaos levels:")
+	fmt.Fprintln(out, "  low   lua, python, node and go (the ptrsg 1.0.0 set)")
+	fmt.Fprintln(out, "  high  everything in low plus php, perl, c, cpp and rust")
+	fmt.Fprintf(out, "\nOptional languages (--extra-langs): %s\n", strings.Join(optionalLangs, ", "))
+}
+
+// stringList is a flag.Value that collects every use of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// checkOutputFlags rejects extra compiler flags that would move the compiled
+// binary away from the path ptrsg runs it from.
+func checkOutputFlags(flagName string, flags []string, banned ...string) error {
+	for _, f := range flags {
+		for _, b := range banned {
+			if f == b || strings.HasPrefix(f, b) {
+				return fmt.Errorf("%s must not set the output path (%s)", flagName, f)
+			}
+		}
+	}
+	return nil
+}
+
+// diag is where verbose logging and other diagnostics go. It's stdout unless
+// stdout is reserved for a payload (--quiet, --json or raw --emit-bytes).
+var diag io.Writer = os.Stdout
+
+// toolPaths maps a bare tool name to the path exec.LookPath resolved it to
+// during preflight. Tools that were never probed fall back to their binary.
+var toolPaths = map[string]string{}
+
+// toolBinaries maps a bare tool name to the binary --cc, --cxx and friends
+// picked for it, like g++ to g++-13.
+var toolBinaries = map[string]string{}
+
+// toolBinary returns the binary to look up for name: its override, if one
+// was given, or name itself.
+func toolBinary(name string) string {
+	if b, ok := toolBinaries[name]; ok {
+		return b
+	}
+	return name
+}
+
+// toolPath returns the resolved path for name, or its binary if unknown.
+func toolPath(name string) string {
+	if p, ok := toolPaths[name]; ok {
+		return p
+	}
+	return toolBinary(name)
+}
+
+// toolFlags are the flags that override a tool's binary, by tool name.
+var toolFlags = []struct{ flag, tool string }{
+	{"cc", "cc"},
+	{"cxx", "g++"},
+	{"rustc", "rustc"},
+	{"go", "go"},
+	{"tinygo", "tinygo"},
+	{"zig", "zig"},
+	{"swiftc", "swiftc"},
+	{"ghc", "ghc"},
+	{"lua", "lua"},
+	{"python", "python"},
+	{"node", "node"},
+	{"php", "php"},
+	{"perl", "perl"},
+}
+
+// toolProbe is a tool preflight checks for and the flags used to query it.
+type toolProbe struct {
+	name  string
+	flags []string
+}
+
+// toolStatus is what preflight learned about one tool.
+type toolStatus struct {
+	Found   bool
+	Path    string
+	Version string
+	Family  string
+	Output  string
+	Err     string
+}
+
+// versionRe picks the first dotted version number out of a --version banner.
+var versionRe = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// parseVersion returns the leading semver-ish token in out, or "" if there
+// isn't one.
+func parseVersion(out string) string {
+	return versionRe.FindString(out)
+}
+
+// compilerFamily tells gcc from clang by a C compiler's --version banner,
+// since cc and g++ are often clang underneath (always on macOS). It's ""
+// when the banner names neither.
+func compilerFamily(banner string) string {
+	switch lower := strings.ToLower(banner); {
+	case strings.Contains(lower, "clang"):
+		return "clang"
+	case strings.Contains(lower, "gcc"), strings.Contains(lower, "free software foundation"):
+		return "gcc"
+	}
+	return ""
+}
+
+// clangFlags go ahead of any user flags when cc or g++ turned out to be
+// clang. gcc accepts warning options it doesn't know and flags a step
+// doesn't use without a word, and clang warns about both; these make
+// clang as quiet, so --cflags-cpp written for gcc doesn't bury the build
+// output, or fail it under -Werror.
+var clangFlags = []string{"-Qunused-arguments", "-Wno-unknown-warning-option"}
+
+// familyFlags is clangFlags if preflight found tool to be clang, and
+// nothing otherwise.
+func familyFlags(tool string) []string {
+	if toolStatuses[tool].Family == "clang" {
+		return clangFlags
+	}
+	return nil
+}
+
+// langProbes says which tool each language needs and how to ask it for its
+// version. go isn't probed, so a missing go surfaces as a compile failure;
+// tinygo is probed separately when --go-compiler asks for it.
+var langProbes = map[string]toolProbe{
+	"lua":     {"lua", []string{"-v"}},
+	"python":  {"python", []string{"--version"}},
+	"node":    {"node", []string{"--version"}},
+	"php":     {"php", []string{"--version"}},
+	"perl":    {"perl", []string{"--version"}},
+	"c":       {"cc", []string{"--version"}},
+	"cpp":     {"g++", []string{"--version"}},
+	"rust":    {"rustc", []string{"--version"}},
+	"zig":     {"zig", []string{"version"}},
+	"swift":   {"swiftc", []string{"--version"}},
+	"haskell": {"ghc", []string{"--version"}},
+}
+
+// requiredTools is what preflight probes for the languages cfg selects.
+func requiredTools(cfg config) []toolProbe {
+	var tools []toolProbe
+	for _, lang := range append(interpretedLangs(cfg), compiledLangs(cfg)...) {
+		if p, ok := langProbe(lang); ok {
+			tools = append(tools, p)
+		}
+	}
+	if cfg.goCompiler == "tinygo" {
+		tools = append(tools, toolProbe{"tinygo", []string{"version"}})
+	}
+	return tools
+}
+
+// fallbackChaos is --fallback-chaos: when high chaos is missing tools that
+// low chaos doesn't need, it returns "low" and the statuses of just the
+// tools low needs, out of those preflight already probed. Otherwise it
+// returns cfg.chaos and tools unchanged.
+func fallbackChaos(cfg config, tools map[string]toolStatus) (string, map[string]toolStatus) {
+	if cfg.chaos != "high" || len(missingTools(tools)) == 0 {
+		return cfg.chaos, tools
+	}
+	low := cfg
+	low.chaos = "low"
+	kept := make(map[string]toolStatus)
+	for _, t := range requiredTools(low) {
+		kept[t.name] = tools[t.name]
+	}
+	if len(missingTools(kept)) > 0 {
+		return cfg.chaos, tools
+	}
+	return low.chaos, kept
+}
+
+// preflightLangCheck resolves each required tool through exec.LookPath and
+// probes its version, returning a status for every tool it checked. Tools
+// that couldn't be found or run have Found false; missingTools lists them.
+// The resolved paths and captured versions are kept so later exec calls run
+// exactly what was probed. Only the tools for the languages cfg selects are
+// probed, so low chaos never needs g++ or rustc.
+func preflightLangCheck(cfg config) map[string]toolStatus {
+	v := cfg.verbosity
+	tools := requiredTools(cfg)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := make(map[string]toolStatus)
+
+	for _, t := range tools {
+		wg.Add(1)
+		go func(name string, flags []string) {
+			defer wg.Done()
+			path, err := exec.LookPath(toolBinary(name))
+			if err != nil {
+				if v == VerbosityHeavy {
+					fmt.Fprintf(diag, "[DEBUG] %s not found in PATH: %v\n", name, err)
+				}
+				mu.Lock()
+				statuses[name] = toolStatus{Err: err.Error()}
+				mu.Unlock()
+				cfg.hooks.toolProbed(name, false, "")
+				return
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			cmd := exec.Command(path, flags...)
+			out, err := cmd.CombinedOutput()
+			if v == VerbosityHeavy {
+				fmt.Fprintf(diag, "[DEBUG] %s resolved to %s\n", name, path)
+				fmt.Fprintf(diag, "[DEBUG] %s %s → ", name, strings.Join(flags, " "))
+				if err != nil {
+					fmt.Fprintf(diag, "error: %v\n", err)
+				} else {
+					fmt.Fprintln(diag, strings.TrimSpace(string(out)))
+				}
+			}
+			text := strings.TrimSpace(string(out))
+			mu.Lock()
+			st := toolStatus{Found: err == nil, Path: path, Version: parseVersion(text), Output: text}
+			if err != nil {
+				st.Err = err.Error()
+			}
+			if name == "cc" || name == "g++" || name == "clang++" {
+				st.Family = compilerFamily(text)
+				if v == VerbosityHeavy && st.Family != "" {
+					fmt.Fprintf(diag, "[DEBUG] %s is %s\n", name, st.Family)
+				}
+			}
+			statuses[name] = st
+			mu.Unlock()
+			cfg.hooks.toolProbed(name, st.Found, st.Version)
+		}(t.name, t.flags)
+	}
+	wg.Wait()
+
+	if v == VerbosityHeavy && len(missingTools(statuses)) == 0 {
+		fmt.Fprintln(diag, "[DEBUG] Preflight check passed: all required tools are available")
+	}
+	return statuses
+}
+
+// missingTools lists, in sorted order, the tools preflight couldn't use.
+func missingTools(tools map[string]toolStatus) []string {
+	var missing []string
+	for name, t := range tools {
+		if !t.Found {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// sourceMode is the mode task sources are written with.
+func sourceMode(cfg config) os.FileMode {
+	if cfg.fileMode != 0 {
+		return cfg.fileMode
+	}
+	return 0644
+}
+
+// binaryMode is --file-mode with an execute bit for everyone who can read.
+func binaryMode(mode os.FileMode) os.FileMode {
+	return mode | mode&0444>>2
+}
+
+// chmodBinary applies --file-mode to a compiled task. Without it the
+// compiler's own mode stands.
+func chmodBinary(exe string, cfg config) error {
+	if cfg.fileMode == 0 {
+		return nil
+	}
+	return os.Chmod(exe, binaryMode(cfg.fileMode))
+}
+
+// writeFiles writes each language's snippet for workload into tmpdir,
+// wrapped for self-timing when selfTime is set and printing an address when
+// aslr is.
+func writeFiles(tmpdir, workload string, langs []string, selfTime, aslr bool, mode os.FileMode) (map[string]string, error) {
+	paths := make(map[string]string)
+	for _, lang := range langs {
+		ext := map[string]string{
+			"lua":    "lua",
+			"python": "py",
+			"node":   "js",
+			"php":    "php",
+			"perl":   "pl",
+		}[lang]
+		fname := fmt.Sprintf("task.%s", ext)
+		path := filepath.Join(tmpdir, fname)
+		code := codeMap[workload][lang]
+		if selfTime {
+			code = selfTimed(lang, code)
+		}
+		if aslr {
+			code = addrPrinting(lang, code)
+		}
+		if err := os.WriteFile(path, []byte(code), mode); err != nil {
+			return nil, writeTaskError(lang, path, err)
+		}
+		paths[lang] = path
+	}
+	return paths, nil
+}
+
+func compileCpp(ctx context.Context, path string, cfg config) (string, error) {
+	return buildCpp(ctx, "g++", filepath.Join(filepath.Dir(path), "task_cpp.exe"), path, cfg)
+}
+
+// buildCpp compiles the cpp task at path into exe with tool, g++ unless
+// --cpp-compilers picks another.
+func buildCpp(ctx context.Context, tool, exe, path string, cfg config) (string, error) {
+	args := append([]string{"-O0", path, "-o", exe}, familyFlags(tool)...)
+	args = append(args, cfg.cppFlags...)
+	if cfg.cppThreads {
+		// libstdc++ runs std::execution::par on TBB when its headers are
+		// installed, and then needs it linked. Without TBB the build
+		// fails to link and is retried without it, on the serial backend.
+		args = append(args, "-std=c++17")
+		err := runCppCompile(ctx, tool, append(args, "-ltbb"), cfg)
+		if err == nil || ctx.Err() != nil {
+			return exe, err
+		}
+		if cfg.verbosity == VerbosityHeavy {
+			fmt.Fprintf(diag, "[DEBUG] linking with -ltbb failed (%v), retrying without it\n", err)
+		}
+	}
+	return exe, runCppCompile(ctx, tool, args, cfg)
+}
+
+// runCompiler runs a compile command. Under --verbose heavy its output
+// goes straight to the terminal; otherwise it's kept, up to maxStderr, and
+// a failed build's error ends with it, so the error says what broke. The
+// compiler is registered with ctx's task group like any timed task, so
+// cleanup waits for it before removing the directory it's writing to.
+func runCompiler(ctx context.Context, cmd *exec.Cmd, cfg config) error {
+	done, err := joinTaskGroup(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	var out stderrCapture
+	if cfg.verbosity == VerbosityHeavy {
+		cmd.Stdout = diag
+		cmd.Stderr = os.Stderr
+	} else {
+		// The same writer for both, so exec never calls it concurrently.
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+	}
+	err = cmd.Run()
+	if output := bytes.TrimSpace(out.buf); err != nil && len(output) > 0 {
+		return fmt.Errorf("%w\n%s", err, output)
+	}
+	return err
+}
+
+func runCppCompile(ctx context.Context, tool string, args []string, cfg config) error {
+	cmd := exec.CommandContext(ctx, toolPath(tool), args...)
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] %s compile: %v\n", tool, cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return runCompiler(ctx, cmd, cfg)
+}
+
+func compileC(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_c.exe")
+	args := append([]string{"-O0", path, "-o", exe}, familyFlags("cc")...)
+	cmd := exec.CommandContext(ctx, toolPath("cc"), args...)
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] cc compile: %v\n", cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+func compileGoFile(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_go.exe")
+	args := []string{"build"}
+	if cfg.goGCFlags != "" {
+		args = append(args, "-gcflags="+cfg.goGCFlags)
+	}
+	args = append(args, "-o", exe, path)
+	cmd := exec.CommandContext(ctx, toolPath(cfg.goCompiler), args...)
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] %s build: %v\n", cfg.goCompiler, cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+func compileRust(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_rust.exe")
+	args := append([]string{"-C", "opt-level=0", path, "-o", exe}, cfg.rustFlags...)
+	cmd := exec.CommandContext(ctx, toolPath("rustc"), args...)
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] rustc compile: %v\n", cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+// interpretedLangs lists the interpreted tasks cfg selects, none at all
+// with --no-interpreted.
+func interpretedLangs(cfg config) []string {
+	if cfg.noInterpreted {
+		return nil
+	}
+	langs := []string{"lua", "python", "node"}
+	if cfg.chaos == "high" {
+		langs = append(langs, "php", "perl")
+	}
+	return langs
+}
+
+// optionalLangs are compiled tasks no chaos level includes, because their
+// toolchains are rare enough that requiring them would break most setups.
+// They only run when named in --extra-langs.
+var optionalLangs = []string{"zig", "swift", "haskell"}
+
+func compileZig(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_zig.exe")
+	cmd := exec.CommandContext(ctx, toolPath("zig"), "build-exe", "-O", "Debug", path, "-femit-bin="+exe)
+	cmd.Dir = dir
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] zig compile: %v\n", cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+func compileSwift(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_swift.exe")
+	cmd := exec.CommandContext(ctx, toolPath("swiftc"), "-Onone", path, "-o", exe)
+	cmd.Dir = dir
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] swiftc compile: %v\n", cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+func compileHaskell(ctx context.Context, path string, cfg config) (string, error) {
+	dir := filepath.Dir(path)
+	exe := filepath.Join(dir, "task_haskell.exe")
+	// The .hi and .o files go in their own directory so they can't clash
+	// with anything else in the temp directory.
+	cmd := exec.CommandContext(ctx, toolPath("ghc"), "-O0", "-outputdir", filepath.Join(dir, "ghc_out"), path, "-o", exe)
+	cmd.Dir = dir
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] ghc compile: %v\n", cmd.Args)
+	}
+	printCommand(cfg, cmd)
+	return exe, runCompiler(ctx, cmd, cfg)
+}
+
+// compiledLangs lists the compiled tasks cfg selects. With --cpp-compilers
+// the cpp task becomes one cpp-<compiler> task per compiler.
+func compiledLangs(cfg config) []string {
+	langs := []string{"go"}
+	if cfg.chaos == "high" {
+		langs = []string{"go", "c"}
+		if len(cfg.cppCompilers) == 0 {
+			langs = append(langs, "cpp")
+		}
+		for _, c := range cfg.cppCompilers {
+			langs = append(langs, "cpp-"+c)
+		}
+		langs = append(langs, "rust")
+	}
+	return append(langs, cfg.extraLangs...)
+}
+
+// writeAndCompileExtra writes and builds every compiled task, returning the
+// executables by language. Every build is attempted; if some fail, the ones
+// that worked are returned alongside the joined errors. If compiled is
+// non-nil it's also called with each executable as soon as that build
+// finishes, which is how the pipeline concurrency model starts running them
+// early.
+func writeAndCompileExtra(ctx context.Context, tmpdir string, cfg config, compiled func(lang, exe string)) (map[string]string, error) {
+	compilers := map[string]func(context.Context, string, config) (string, error){
+		"c":       compileC,
+		"cpp":     compileCpp,
+		"go":      compileGoFile,
+		"rust":    compileRust,
+		"zig":     compileZig,
+		"swift":   compileSwift,
+		"haskell": compileHaskell,
+	}
+
+	langs := compiledLangs(cfg)
+
+	result := make(map[string]string)
+	failed := make(map[string]error)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	lim := newLimiter(cfg.parallel)
+	for _, lang := range langs {
+		ext := map[string]string{"c": "c", "cpp": "cpp", "go": "go", "rust": "rs", "zig": "zig", "swift": "swift", "haskell": "hs"}[baseLang(lang)]
+		compile := compilers[lang]
+		path := filepath.Join(tmpdir, fmt.Sprintf("task.%s", ext))
+		if cppVariant(lang) != "" {
+			// Every variant builds the same snippet, each from its own copy.
+			compile = cppCompiler(lang)
+			path = filepath.Join(tmpdir, fmt.Sprintf("task_%s.%s", lang, ext))
+		}
+		if err := os.WriteFile(path, []byte(compiledSource(lang, cfg)), sourceMode(cfg)); err != nil {
+			return nil, writeTaskError(lang, path, err)
+		}
+		wg.Add(1)
+		go func(lang, path string, compile func(context.Context, string, config) (string, error)) {
+			defer wg.Done()
+			lim.acquire()
+			defer lim.release()
+			var exe string
+			err := withRetries(ctx, cfg, "compiling "+lang, func() error {
+				return compileWatchdog(ctx, cfg, compileTool(lang, cfg), func(ctx context.Context) error {
+					err := compileTimes.time(lang, func() error {
+						var err error
+						exe, err = compile(ctx, path, cfg)
+						return err
+					})
+					if err != nil {
+						return err
+					}
+					return chmodBinary(exe, cfg)
+				})
+			})
+			if err == nil {
+				prog.step("compiled " + lang)
+				cfg.hooks.compiled(lang, compileTimes.duration(lang))
+				if compiled != nil {
+					compiled(lang, exe)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[lang] = err
+				return
+			}
+			result[lang] = exe
+		}(lang, path, compile)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		errs := make([]error, 0, len(failed))
+		for _, lang := range langs {
+			if err, ok := failed[lang]; ok {
+				errs = append(errs, &ErrCompile{Lang: lang, Err: err})
+			}
+		}
+		return result, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// compileWatchdog runs one compile attempt with tool under
+// --compile-timeout, or tool's own --timeout-lang limit if it has one. The
+// compiler gets killed once the timeout passes, and the error says it
+// stalled rather than just reporting the kill signal. It's killed too if
+// parent is cancelled.
+func compileWatchdog(parent context.Context, cfg config, tool string, fn func(ctx context.Context) error) error {
+	timeout := cfg.compileTimeout
+	if d, ok := cfg.langTimeouts[tool]; ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return fn(parent)
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	err := fn(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("stalled, killed after %s", timeout)
+	}
+	return err
+}
+
+// compileTool is the compiler that builds compiled task lang, by the name
+// --timeout-lang and --explain-chaos know it by.
+func compileTool(lang string, cfg config) string {
+	if lang == "go" {
+		return cfg.goCompiler
+	}
+	p, _ := langProbe(lang)
+	return p.name
+}
+
+// limiter caps how many tasks run at once. A nil limiter never blocks.
+type limiter chan struct{}
+
+func newLimiter(n int) limiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(limiter, n)
+}
+
+func (l limiter) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+func (l limiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// sample is what a single task run left behind.
+type sample struct {
+	ns        int64
+	exitCode  int
+	maxRSS    int64
+	counter   int64
+	runs      []int64
+	binSize   int64
+	ctxsw     int64
+	addr      int64
+	stderrLen int64
+	stderr    []byte
+	compileNs int64
+}
+
+func timeRun(ctx context.Context, cmdArgs []string, cfg config) (sample, error) {
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] Running: %v\n", cmdArgs)
+	}
+	name := filepath.Base(cmdArgs[0])
+	if cfg.perfEvent != "" {
+		cmdArgs = perfArgs(cfg.perfEvent, cmdArgs)
+	}
+	done, err := joinTaskGroup(ctx)
+	if err != nil {
+		return sample{}, err
+	}
+	defer done()
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	if cfg.taskThreads > 0 {
+		cmd.Env = taskEnv(cfg.taskThreads)
+	}
+	var stdout, stderr bytes.Buffer
+	captureStdout := cfg.excludeStartup || cfg.mixASLR
+	if captureStdout {
+		cmd.Stdout = &stdout
+	}
+	if cfg.perfEvent != "" {
+		cmd.Stderr = &stderr
+	}
+	if cfg.verbosity == VerbosityHeavy {
+		cmd.Stdout = diag
+		if captureStdout {
+			cmd.Stdout = io.MultiWriter(diag, &stdout)
+		}
+		cmd.Stderr = os.Stderr
+		if cfg.perfEvent != "" {
+			cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		}
+	}
+	var collected stderrCapture
+	if cfg.collectStderr {
+		if cmd.Stderr == nil {
+			cmd.Stderr = &collected
+		} else {
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, &collected)
+		}
+	}
+	printCommand(cfg, cmd)
+	// time.Now carries a monotonic reading and time.Since uses it, so NTP
+	// steps and wall-clock changes can't leak into the measurement. Suspend
+	// and resume still can, which clampDuration catches.
+	start := time.Now()
+	err = cmd.Run()
+	d := time.Since(start)
+	elapsed, ok := clampDuration(d)
+	if !ok {
+		warnf("%s took an implausible %s, clamped to %s", name, d, time.Duration(elapsed))
+	}
+	if ctx.Err() != nil {
+		return sample{}, ctx.Err()
+	}
+	if cfg.excludeStartup {
+		if ns, ok := parseSelfTime(stdout.Bytes()); ok {
+			elapsed, _ = clampDuration(time.Duration(ns))
+		}
+	}
+	if cfg.sampleClock == "process-cpu" && cmd.ProcessState != nil {
+		elapsed, _ = clampDuration(cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime())
+	}
+	smp := sample{ns: elapsed}
+	if cfg.measureMemory && cmd.ProcessState != nil {
+		smp.maxRSS = maxRSS(cmd.ProcessState)
+	}
+	if cfg.mixCtxsw && cmd.ProcessState != nil {
+		smp.ctxsw = ctxSwitches(cmd.ProcessState)
+	}
+	if cfg.mixASLR {
+		smp.addr, _ = parseAddress(stdout.Bytes())
+	}
+	smp.stderrLen, smp.stderr = collected.n, collected.buf
+	if cfg.perfEvent != "" {
+		if n, ok := parsePerfCounter(stderr.Bytes(), cfg.perfEvent); ok {
+			smp.counter = n
+		} else {
+			warnf("perf reported no %s count for %s", cfg.perfEvent, name)
+		}
+	}
+	var exitErr *exec.ExitError
+	if cfg.mixExitCodes && errors.As(err, &exitErr) {
+		smp.exitCode = exitErr.ExitCode()
+		err = nil
+	}
+	return smp, err
+}
+
+// threadEnvVars are the variables the task runtimes read their thread
+// counts from: Go, rayon for rust, OpenMP, and libuv's pool under node.
+var threadEnvVars = []string{"GOMAXPROCS", "RAYON_NUM_THREADS", "OMP_NUM_THREADS", "UV_THREADPOOL_SIZE"}
+
+// taskEnv is the current environment with every threadEnvVars entry set to
+// n. Later entries win in exec, so appending overrides anything inherited.
+func taskEnv(n int) []string {
+	env := os.Environ()
+	for _, v := range threadEnvVars {
+		env = append(env, fmt.Sprintf("%s=%d", v, n))
+	}
+	return env
+}
+
+// maxTaskDuration is the longest timing ptrsg believes. Anything past it is
+// almost certainly a suspended machine rather than a slow task.
+const maxTaskDuration = time.Hour
+
+// clampDuration keeps d inside [0, maxTaskDuration] so it always encodes
+// sanely as a uint64. ok is false if d had to be clamped.
+func clampDuration(d time.Duration) (ns int64, ok bool) {
+	switch {
+	case d < 0:
+		return 0, false
+	case d > maxTaskDuration:
+		return maxTaskDuration.Nanoseconds(), false
+	}
+	return d.Nanoseconds(), true
+}
+
+// sweepBits are the seed widths --sweep prints.
+var sweepBits = []int{32, 64, 128, 256, 512}
+
+// maxBackoff caps the sleep between --retries attempts.
+const maxBackoff = 2 * time.Second
+
+// withRetries calls fn until it succeeds, cfg.retries retries have been
+// used up or ctx is done, doubling the sleep between attempts from 100ms.
+func withRetries(ctx context.Context, cfg config, what string, fn func() error) error {
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= cfg.retries || ctx.Err() != nil {
+			return err
+		}
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "%s failed (%v), retrying in %s...\n", what, err, backoff)
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// timeRunRetry is timeRun wrapped in withRetries, each attempt limited by
+// lang's --timeout-lang if it has one. The timing comes from the attempt
+// that succeeded.
+func timeRunRetry(ctx context.Context, lang string, cmdArgs []string, cfg config) (sample, error) {
+	var smp sample
+	limit := cfg.langTimeouts[lang]
+	err := withRetries(ctx, cfg, "running "+lang, func() error {
+		if limit <= 0 {
+			var err error
+			smp, err = timeRun(ctx, cmdArgs, cfg)
+			return err
+		}
+		runCtx, cancel := context.WithTimeout(ctx, limit)
+		defer cancel()
+		var err error
+		smp, err = timeRun(runCtx, cmdArgs, cfg)
+		if err != nil && ctx.Err() == nil && runCtx.Err() != nil {
+			// Not a --max-runtime cut-off, which runStream drops quietly.
+			err = fmt.Errorf("timed out, killed after %s", limit)
+		}
+		return err
+	})
+	if err != nil && ctx.Err() == nil {
+		code := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		err = &ErrRun{Lang: lang, ExitCode: code, Err: err}
+	}
+	return smp, err
+}
+
+// timeTask times one task --iterations times back to back. The sample's ns is
+// the sum of every iteration, so all of their variance reaches the hash, and
+// runs keeps each one. Peak memory is the largest seen, perf counters,
+// context switches, addresses (wrapping) and stderr lengths are summed,
+// collected stderr is concatenated up to maxStderr and the exit code is the
+// last one.
+func timeTask(ctx context.Context, lang string, cmdArgs []string, cfg config) (sample, error) {
+	if cfg.persistent && persistentDrivers[lang] != "" {
+		return timePersistent(ctx, lang, cmdArgs, cfg)
+	}
+	var total sample
+	for i := 0; i < max(cfg.iterations, 1); i++ {
+		args := cmdArgs
+		if cfg.affinityTaskset != "" {
+			args = append([]string{cfg.affinityTaskset, "-c", strconv.Itoa(i % runtime.NumCPU())}, cmdArgs...)
+		}
+		smp, err := timeRunRetry(ctx, lang, args, cfg)
+		if err != nil {
+			return sample{}, err
+		}
+		total.ns += smp.ns
+		total.exitCode = smp.exitCode
+		total.maxRSS = max(total.maxRSS, smp.maxRSS)
+		total.counter += smp.counter
+		total.ctxsw += smp.ctxsw
+		total.addr = int64(uint64(total.addr) + uint64(smp.addr))
+		total.stderrLen += smp.stderrLen
+		if room := maxStderr - len(total.stderr); room > 0 {
+			total.stderr = append(total.stderr, smp.stderr[:min(room, len(smp.stderr))]...)
+		}
+		total.runs = append(total.runs, smp.ns)
+	}
+	return total, nil
+}
+
+// withExecHint adds a --tmpdir suggestion to errors that look like the temp
+// directory is on a noexec mount.
+func withExecHint(err error, tmpdir string) error {
+	if err == nil || !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return fmt.Errorf("%w\nhint: %s may be on a noexec filesystem; try --tmpdir with a directory that allows running programs", err, tmpdir)
+}
+
+// primeBinaries runs every compiled task once and throws the result away.
+// On Windows the first launch of a fresh exe pays for an antivirus scan and
+// a cold file cache, which would otherwise land in the measured timing.
+func primeBinaries(ctx context.Context, extra map[string]string, cfg config) error {
+	langs := make([]string, 0, len(extra))
+	for lang := range extra {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "Priming %s...\n", lang)
+		}
+		if err := primeBinary(ctx, extra[lang]); err != nil {
+			return fmt.Errorf("priming %s: %w", lang, err)
+		}
+	}
+	return nil
+}
+
+// primeBinary runs exe once under ctx's task group.
+func primeBinary(ctx context.Context, exe string) error {
+	done, err := joinTaskGroup(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return exec.CommandContext(ctx, exe).Run()
+}
+
+// Result is everything a run produces. Raw is the seed after -S truncation as
+// big-endian bytes and Seed is the same value as an integer. Rand is the
+// --rand-impl generator seeded from Raw; the default v1 only sees the low 64
+// bits (see prngBits), so use Raw when the full width matters. Seeds holds
+// every seed --seed-count asked for, starting with Seed. ExitCodes is only
+// set with --mix-exit-codes, MaxRSS (bytes) with --measure-memory and
+// Counters with --perf. Runs holds every iteration's timing when --iterations
+// is above 1, and Timings is then their sum. Baseline is the --bench-baseline
+// calibration time in ns, BinSizes the --mix-binsize executable sizes and
+// CtxSwitches the --mix-ctxsw counts. StderrLengths and StderrDigests are the
+// --collect-stderr byte counts and hex blake2b-256 digests, and CompileTimes
+// the --include-compile-time build durations in ns. Addresses are the
+// --mix-aslr ones, summed over iterations, 0 for tasks that printed none.
+// Beacon is the --beacon value that went into the hash, if one was fetched,
+// and BufferLayout the --buffer-layout it was hashed in. EntropyBits is a
+// rough estimate of how much of the seed the measurements can vouch for, and
+// Degraded is set when too few languages were timed to trust it (see
+// entropyEstimate).
+type Result struct {
+	Timings       map[string]int64
+	ExitCodes     map[string]int
+	MaxRSS        map[string]int64
+	Counters      map[string]int64
+	BinSizes      map[string]int64
+	CtxSwitches   map[string]int64
+	StderrLengths map[string]int64
+	StderrDigests map[string]string
+	CompileTimes  map[string]int64
+	Addresses     map[string]int64
+	Beacon        []byte
+	BufferLayout  string
+	Runs          map[string][]int64
+	Baseline      int64
+	Hash          []byte
+	Raw           []byte
+	Seed          *big.Int
+	Seeds         []*big.Int
+	Rand          PRNG
+	EntropyBits   int
+	Degraded      bool
+}
+
+// Generate writes, compiles and times every task cfg selects, then derives
+// the seed from the timings. Preflight must already have run.
+func Generate(cfg config) (*Result, error) {
+	tmpdir, err := os.MkdirTemp(cfg.tmpdir, "prandom_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// Deferred after RemoveAll so it runs first, panic or not: kill every
+	// task and compiler still running and wait until they're all reaped.
+	ctx, cancel := context.WithCancel(context.Background())
+	group := &taskGroup{}
+	ctx = withTaskGroup(ctx, group)
+	defer func() {
+		cancel()
+		group.closeAndWait()
+	}()
+
+	if cfg.verbosity >= VerbosityLite {
+		fmt.Fprintf(diag, "Preparing files in %s...\n", tmpdir)
+	}
+
+	compiles := len(compiledLangs(cfg))
+	for _, l := range cfg.manifest {
+		if len(l.Compile) > 0 {
+			compiles++
+		}
+	}
+	runs := len(interpretedLangs(cfg)) + len(compiledLangs(cfg)) + len(cfg.manifest) + len(cfg.extraCmds)
+	prog = newProgress(compiles+runs, cfg)
+	defer func() {
+		prog.finish()
+		prog = nil
+	}()
+
+	var samples map[string]sample
+	if cfg.concurrencyModel == "pipeline" {
+		samples, err = runPipeline(ctx, tmpdir, cfg, runs)
+	} else {
+		procMap, manifestErr := taskCommands(ctx, tmpdir, cfg)
+		if procMap == nil {
+			return nil, manifestErr
+		}
+		samples, err = compileThenRun(ctx, tmpdir, procMap, cfg)
+		err = errors.Join(manifestErr, err)
+	}
+	partial := false
+	if err != nil {
+		if samples, err = keepPartial(samples, err, cfg); err != nil {
+			return nil, err
+		}
+		partial = true
+	}
+	if cfg.mixBinsize {
+		addBinSizes(tmpdir, samples)
+	}
+	if cfg.includeCompileTime {
+		addCompileTimes(samples)
+	}
+	res, err := resultFromSamples(samples, cfg)
+	if res != nil && partial {
+		res.Degraded = true
+	}
+	return res, err
+}
+
+// resultFromSamples derives the seeds from one round of measurements and
+// packs everything into a Result.
+func resultFromSamples(samples map[string]sample, cfg config) (*Result, error) {
+	// Hashing nothing would hand back the same seed every time, so an empty
+	// language set is an error rather than a result.
+	if len(samples) == 0 {
+		return nil, errors.New("no languages were timed; refusing to derive a seed from an empty set")
+	}
+	timings := make(map[string]int64, len(samples))
+	var iterRuns map[string][]int64
+	if cfg.iterations > 1 {
+		iterRuns = make(map[string][]int64, len(samples))
+	}
+	for lang, smp := range samples {
+		if cfg.dropOutliers > 0 {
+			smp = dropOutliers(smp, cfg.dropOutliers)
+			samples[lang] = smp
+		}
+		timings[lang] = smp.ns
+		if iterRuns != nil {
+			iterRuns[lang] = smp.runs
+		}
+	}
+	if cfg.timingsOnly {
+		res := &Result{Timings: timings, Runs: iterRuns}
+		if cfg.benchBaseline {
+			res.Baseline = int64(calibrate())
+		}
+		return res, nil
+	}
+	if cfg.debias && debiasedEmpty(hashedTimings(timings, cfg)) {
+		return nil, errors.New("--debias kept no bits from the timings; refusing to derive a seed from them")
+	}
+
+	if cfg.reorderGuard {
+		if err := reorderGuard(samples, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Everything besides the timings goes into mix: sampleMix's part first,
+	// then the rest in the order written below. The README's Hash buffer
+	// section is the byte-by-byte spec; keep it in step.
+	mix, res := sampleMix(samples, cfg)
+
+	coarseTimings(timings, cfg)
+	for range shortTimings(timings, cfg) {
+		mix = binary.BigEndian.AppendUint64(mix, clockJitter())
+	}
+
+	if cfg.pool != "" {
+		poolTail, err := readPoolTail(cfg.pool)
+		if err != nil {
+			return nil, err
+		}
+		mix = append(mix, poolTail...)
+	}
+
+	var beacon []byte
+	if cfg.beacon != "" {
+		var err error
+		if beacon, err = beaconMix(cfg); err != nil {
+			return nil, err
+		}
+		mix = append(mix, beacon...)
+	}
+
+	if cfg.dumpBuffer != "" {
+		if err := dumpBuffer(cfg.dumpBuffer, hashBuffer(timings, cfg, counterMix(mix, cfg, 0))); err != nil {
+			return nil, err
+		}
+	}
+	hash, _ := deriveSeed(timings, cfg, counterMix(mix, cfg, 0))
+	cfg.hooks.hashed(hash)
+	// Its leading bytes are the seed, which --commit withholds.
+	if cfg.verbosity == VerbosityHeavy && cfg.commit == "" {
+		fmt.Fprintf(diag, "[DEBUG] Full Blake2b: %x\n", hash)
+	}
+
+	raw, err := seedRaw(hash, cfg.seedBits, cfg)
+	if err != nil {
+		return nil, err
+	}
+	seed := new(big.Int).SetBytes(raw)
+	seeds := []*big.Int{seed}
+	for i := 1; i < cfg.seedCount; i++ {
+		h, _ := deriveSeed(timings, cfg, counterMix(mix, cfg, i))
+		r, err := seedRaw(h, cfg.seedBits, cfg)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, new(big.Int).SetBytes(r))
+	}
+
+	if cfg.pool != "" {
+		if err := appendPool(cfg.pool, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	// Measured after the tasks so it doesn't compete with them.
+	var baseline int64
+	if cfg.benchBaseline {
+		baseline = int64(calibrate())
+	}
+
+	res.Timings = timings
+	res.Runs = iterRuns
+	res.Beacon = beacon
+	res.BufferLayout = cfg.bufferLayout
+	res.Baseline = baseline
+	res.Hash = hash
+	res.Raw = raw
+	res.Seed = seed
+	res.Seeds = seeds
+	res.Rand = newPRNG(raw, cfg)
+	res.EntropyBits, res.Degraded = entropyEstimate(len(timings), cfg)
+	return res, nil
+}
+
+// minSources is the fewest timed languages a seed is trusted from. Below
+// it the seed still gets derived, but it's reported as degraded.
+const minSources = 3
+
+// entropyEstimate guesses how many bits of the seed n timings are good for,
+// capped at -S. It credits each timing with what --debias keeps of its
+// noisy low bits on average, a quarter of debiasBits, which is deliberately
+// conservative. --mix-os-entropy makes the seed at least as strong as the
+// OS CSPRNG, so then it's the full -S and never degraded.
+func entropyEstimate(n int, cfg config) (bits int, degraded bool) {
+	if cfg.mixOSEntropy {
+		return cfg.seedBits, false
+	}
+	return min(n*debiasBits/4, cfg.seedBits), n < minSources
+}
+
+// sampleMix encodes the per-language extras cfg asks for into the start of
+// mix, from the --fold all runs to the --mix-aslr addresses, in the order
+// and encodings the README's Hash buffer section gives. It also returns them
+// as a Result with just those maps set, each nil when not asked for.
+func sampleMix(samples map[string]sample, cfg config) ([]byte, *Result) {
+	var mix []byte
+	res := &Result{}
+	if cfg.fold == "all" {
+		runs := make(map[string][]int64, len(samples))
+		for lang, smp := range samples {
+			runs[lang] = smp.runs
+		}
+		mix = runBytes(runs, cfg)
+	}
+
+	if cfg.mixExitCodes {
+		res.ExitCodes = make(map[string]int, len(samples))
+		for lang, smp := range samples {
+			res.ExitCodes[lang] = smp.exitCode
+		}
+		mix = append(mix, exitCodeBytes(res.ExitCodes)...)
+	}
+
+	if cfg.measureMemory && maxRSSSupported {
+		res.MaxRSS = make(map[string]int64, len(samples))
+		for lang, smp := range samples {
+			res.MaxRSS[lang] = smp.maxRSS
+		}
+		mix = append(mix, int64Mix(res.MaxRSS)...)
+	}
+
+	if cfg.perfEvent != "" {
+		res.Counters = make(map[string]int64, len(samples))
+		for lang, smp := range samples {
+			res.Counters[lang] = smp.counter
+		}
+		mix = append(mix, int64Mix(res.Counters)...)
+	}
+
+	if cfg.mixBinsize {
+		res.BinSizes = make(map[string]int64)
+		for lang, smp := range samples {
+			if smp.binSize > 0 {
+				res.BinSizes[lang] = smp.binSize
+			}
+		}
+		mix = append(mix, int64Mix(res.BinSizes)...)
+	}
+
+	if cfg.mixCtxsw {
+		res.CtxSwitches = make(map[string]int64, len(samples))
+		for lang, smp := range samples {
+			res.CtxSwitches[lang] = smp.ctxsw
+		}
+		mix = append(mix, int64Mix(res.CtxSwitches)...)
+	}
+
+	if cfg.collectStderr {
+		res.StderrLengths = make(map[string]int64, len(samples))
+		res.StderrDigests = make(map[string]string, len(samples))
+		for lang, smp := range samples {
+			res.StderrLengths[lang] = smp.stderrLen
+			res.StderrDigests[lang] = stderrDigest(smp.stderr)
+		}
+		mix = append(mix, stderrBytes(res.StderrLengths, res.StderrDigests)...)
+	}
+
+	if cfg.includeCompileTime {
+		res.CompileTimes = make(map[string]int64)
+		for lang, smp := range samples {
+			if smp.compileNs > 0 {
+				res.CompileTimes[lang] = smp.compileNs
+			}
+		}
+		mix = append(mix, int64Mix(res.CompileTimes)...)
+	}
+
+	if cfg.mixASLR {
+		res.Addresses = make(map[string]int64, len(samples))
+		for lang, smp := range samples {
+			res.Addresses[lang] = smp.addr
+		}
+		mix = append(mix, int64Mix(res.Addresses)...)
+	}
+	return mix, res
+}
+
+// addBinSizes records, for --mix-binsize, the size of every compiled task's
+// executable in tmpdir. Built-in and manifest builds are all named
+// task_<lang>.exe; tasks without one, like the interpreted ones, keep 0.
+func addBinSizes(tmpdir string, samples map[string]sample) {
+	for lang, smp := range samples {
+		if fi, err := os.Stat(filepath.Join(tmpdir, "task_"+lang+".exe")); err == nil {
+			smp.binSize = fi.Size()
+			samples[lang] = smp
+		}
+	}
+}
+
+// exitCodeBytes encodes the exit codes as 4-byte big-endian values in
+// language order, so the layout doesn't depend on map iteration.
+func exitCodeBytes(codes map[string]int) []byte {
+	langs := make([]string, 0, len(codes))
+	for lang := range codes {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	var b []byte
+	for _, lang := range langs {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(codes[lang])))
+	}
+	return b
+}
+
+// runBytes encodes, for --fold all, every iteration's timing as 8 bytes in
+// order, by language and then by iteration, rounded like the timings.
+func runBytes(runs map[string][]int64, cfg config) []byte {
+	langs := make([]string, 0, len(runs))
+	for lang := range runs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	var b []byte
+	for _, lang := range langs {
+		for _, ns := range runs[lang] {
+			var v [8]byte
+			timingOrder(cfg).PutUint64(v[:], uint64(roundTiming(ns, cfg.timingPrecision)))
+			b = append(b, v[:]...)
+		}
+	}
+	return b
+}
+
+// int64Mix encodes a per-language map such as peak RSS, perf counters or
+// compile times as 8-byte big-endian values in language order.
+func int64Mix(vals map[string]int64) []byte {
+	langs := make([]string, 0, len(vals))
+	for lang := range vals {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	var b []byte
+	for _, lang := range langs {
+		b = binary.BigEndian.AppendUint64(b, uint64(vals[lang]))
+	}
+	return b
+}
+
+// seedRaw is the bits-wide seed a digest gives: its leading bits, as
+// truncateHash cuts them, XORed with fresh OS entropy under
+// --mix-os-entropy. The main seed, --seed-count's and --sweep's all come
+// from it.
+func seedRaw(hash []byte, bits int, cfg config) ([]byte, error) {
+	raw := truncateHash(hash, bits)
+	if cfg.mixOSEntropy {
+		if err := xorOSEntropy(raw, bits); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// xorOSEntropy XORs raw, a bits-wide seed, with as many bytes from
+// crypto/rand, masked the same way truncateHash masks the first byte. XOR
+// with an independent uniform value is uniform, so the result is at least as
+// strong as the OS source even if every timing was constant.
+func xorOSEntropy(raw []byte, bits int) error {
+	pad := make([]byte, len(raw))
+	if _, err := crand.Read(pad); err != nil {
+		return fmt.Errorf("reading OS entropy: %w", err)
+	}
+	if bits%8 != 0 {
+		pad[0] >>= 8 - bits%8
+	}
+	for i := range raw {
+		raw[i] ^= pad[i]
+	}
+	return nil
+}
+
+// counterMix appends the 4-byte big-endian --seed-count counter i to mix,
+// HKDF-expand style. With a single seed nothing is appended, so the default
+// derivation is unchanged.
+func counterMix(mix []byte, cfg config, i int) []byte {
+	if cfg.seedCount <= 1 {
+		return mix
+	}
+	return binary.BigEndian.AppendUint32(append([]byte(nil), mix...), uint32(i))
+}
+
+// taskCommands writes the interpreted tasks and the manifest languages into
+// tmpdir, compiling the manifest ones that need it, and returns the command
+// to time for each along with any --extra-cmd. With --fail-fast=false a
+// failed manifest compile comes back as the error next to the other
+// commands; a nil map means nothing should run.
+func taskCommands(ctx context.Context, tmpdir string, cfg config) (map[string][]string, error) {
+	paths, err := writeFiles(tmpdir, cfg.workload, interpretedLangs(cfg), cfg.excludeStartup, cfg.mixASLR, sourceMode(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.precompile {
+		paths = precompile(ctx, paths, cfg)
+	}
+
+	checkDiskSpace(tmpdir)
+
+	procMap := make(map[string][]string)
+	for lang, p := range paths {
+		args := []string{toolPath(lang)}
+		if lang == "node" {
+			args = append(args, cfg.nodeFlags...)
+		}
+		if cfg.persistent && persistentDrivers[lang] != "" {
+			driver, err := writePersistentDriver(lang, p, sourceMode(cfg))
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, driver)
+		}
+		procMap[lang] = append(args, p)
+	}
+
+	for name, args := range cfg.extraCmds {
+		procMap[name] = args
+	}
+
+	var manifestErr error
+	if len(cfg.manifest) > 0 {
+		var procs map[string][]string
+		procs, manifestErr = prepareManifest(ctx, tmpdir, cfg)
+		if manifestErr != nil && cfg.failFast {
+			return nil, manifestErr
+		}
+		for lang, args := range procs {
+			procMap[lang] = args
+		}
+	}
+	return procMap, manifestErr
+}
+
+// compileTasks builds every compiled task and adds it to procMap, primed and
+// isolated as cfg asks. Like taskCommands, failed builds with
+// --fail-fast=false come back as the error next to the rest, and a nil map
+// means nothing should run.
+func compileTasks(ctx context.Context, tmpdir string, procMap map[string][]string, cfg config) (map[string][]string, error) {
+	extra, compileErr := writeAndCompileExtra(ctx, tmpdir, cfg, nil)
+	if compileErr != nil && cfg.failFast {
+		return nil, compileErr
+	}
+	if cfg.primeBinaries {
+		if err := primeBinaries(ctx, extra, cfg); err != nil {
+			return nil, withExecHint(err, tmpdir)
+		}
+	}
+	for lang, exe := range extra {
+		procMap[lang] = []string{exe}
+	}
+	if cfg.isolate {
+		procMap = isolateTasks(procMap, cfg)
+	}
+	if cfg.container != "" {
+		procMap = containerTasks(procMap, tmpdir, cfg)
+	}
+	return procMap, compileErr
+}
+
+// compileThenRun is the simple concurrency model: build every compiled task,
+// then time everything in one go. Like runStream, it can return samples next
+// to an error under --best-effort.
+func compileThenRun(ctx context.Context, tmpdir string, procMap map[string][]string, cfg config) (map[string]sample, error) {
+	procMap, compileErr := compileTasks(ctx, tmpdir, procMap, cfg)
+	if procMap == nil {
+		return nil, compileErr
+	}
+	samples, err := runTasks(ctx, procMap, cfg)
+	if err != nil {
+		err = withExecHint(err, tmpdir)
+	}
+	return samples, errors.Join(compileErr, err)
+}
+
+// runPipeline is the pipeline concurrency model: the compiled tasks start
+// building before anything else is written, the interpreted tasks start
+// right away once their files are, and each compiled task joins them as
+// soon as its build finishes, so rustc isn't holding everything else up.
+// runs is how many tasks there can be in all.
+func runPipeline(ctx context.Context, tmpdir string, cfg config, runs int) (map[string]sample, error) {
+	// Buffered for every task so neither side blocks if the other bails.
+	tasks := make(chan task, runs)
+	compiled := make(chan error, 1)
+	go func() {
+		_, err := writeAndCompileExtra(ctx, tmpdir, cfg, func(lang, exe string) {
+			tasks <- task{lang, []string{exe}}
+		})
+		compiled <- err
+	}()
+
+	procMap, manifestErr := taskCommands(ctx, tmpdir, cfg)
+	if procMap == nil {
+		<-compiled
+		return nil, manifestErr
+	}
+	for lang, args := range procMap {
+		tasks <- task{lang, args}
+	}
+
+	type result struct {
+		samples map[string]sample
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		samples, err := runStream(ctx, tasks, cfg)
+		done <- result{samples, err}
+	}()
+
+	compileErr := <-compiled
+	close(tasks)
+	r := <-done
+	if compileErr != nil && cfg.failFast {
+		return nil, compileErr
+	}
+	if r.err != nil {
+		r.err = withExecHint(r.err, tmpdir)
+	}
+	return r.samples, errors.Join(manifestErr, compileErr, r.err)
+}
+
+// maxDither bounds the random pause --dither adds before each launch.
+const maxDither = 5 * time.Millisecond
+
+// ditherSleep pauses for a random duration below maxDither, drawn from
+// crypto/rand so it can't share structure with anything being measured.
+func ditherSleep() {
+	n, err := crand.Int(crand.Reader, big.NewInt(int64(maxDither)))
+	if err != nil {
+		return
+	}
+	time.Sleep(time.Duration(n.Int64()))
+}
+
+// task is one command for runStream to time.
+type task struct {
+	lang string
+	args []string
+}
+
+// runTasks runs every command in procMap through runStream.
+func runTasks(ctx context.Context, procMap map[string][]string, cfg config) (map[string]sample, error) {
+	tasks := make(chan task, len(procMap))
+	for lang, args := range procMap {
+		tasks <- task{lang, args}
+	}
+	close(tasks)
+	return runStream(ctx, tasks, cfg)
+}
+
+// runStream times every task it receives until tasks is closed, one at a time
+// with --queue and concurrently otherwise, and returns what each run
+// produced. A failed task fails the whole run; with --fail-fast=false the
+// rest still run and every failure is reported together, and --best-effort
+// returns what did finish next to those failures. Once --max-runtime is used
+// up no new tasks start and running ones are killed; their languages are
+// simply left out as long as --min-langs still finished. --stress load runs
+// for exactly as long.
+func runStream(ctx context.Context, tasks <-chan task, cfg config) (map[string]sample, error) {
+	if cfg.stress > 0 {
+		defer startStress(cfg.stress)()
+	}
+	if cfg.maxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.maxRuntime)
+		defer cancel()
+	}
+
+	procMap := make(map[string][]string)
+	timings := make(map[string]sample)
+	var errs []error
+	if cfg.queue {
+		for t := range tasks {
+			procMap[t.lang] = t.args
+			if ctx.Err() != nil {
+				continue
+			}
+			if cfg.verbosity >= VerbosityLite {
+				fmt.Fprintf(diag, "Running %s...\n", t.lang)
+			}
+			if cfg.dither {
+				ditherSleep()
+			}
+			smp, err := timeTask(ctx, t.lang, t.args, cfg)
+			cfg.hooks.ran(t.lang, smp, err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				continue
+			}
+			if err != nil {
+				if cfg.failFast && cfg.retryMeasure == 0 {
+					return nil, err
+				}
+				errs = append(errs, err)
+				continue
+			}
+			prog.step("ran " + t.lang)
+			timings[t.lang] = smp
+		}
+		if len(errs) > 0 && cfg.retryMeasure > 0 {
+			return retryFailed(ctx, timings, procMap, errs, cfg)
+		}
+		if len(errs) > 0 && cfg.bestEffort {
+			return timings, errors.Join(errs...)
+		}
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return checkBudget(timings, procMap, cfg)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	lim := newLimiter(cfg.parallel)
+	for t := range tasks {
+		procMap[t.lang] = t.args
+		wg.Add(1)
+		go func(l string, args []string) {
+			defer wg.Done()
+			lim.acquire()
+			defer lim.release()
+			if ctx.Err() != nil {
+				return
+			}
+			if cfg.dither {
+				ditherSleep()
+			}
+			t, err := timeTask(ctx, l, args, cfg)
+			cfg.hooks.ran(l, t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return
+			}
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			prog.step("ran " + l)
+			timings[l] = t
+		}(t.lang, t.args)
+	}
+	wg.Wait()
+	if len(errs) > 0 && cfg.retryMeasure > 0 {
+		return retryFailed(ctx, timings, procMap, errs, cfg)
+	}
+	if len(errs) > 0 {
+		if cfg.failFast {
+			return nil, errs[0]
+		}
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		if cfg.bestEffort {
+			return timings, errors.Join(errs...)
+		}
+		return nil, errors.Join(errs...)
+	}
+	return checkBudget(timings, procMap, cfg)
+}
+
+// retryFailed is what runStream does with failures under --retry-measure.
+// The failed languages are left out if --min-langs still finished;
+// otherwise they're re-run one at a time, up to --retry-measure rounds,
+// until enough have. errs are the failures so far; whichever languages
+// still haven't finished at the end are reported with their last error.
+func retryFailed(ctx context.Context, timings map[string]sample, procMap map[string][]string, errs []error, cfg config) (map[string]sample, error) {
+	lastErr := make(map[string]error)
+	for _, err := range errs {
+		var run *ErrRun
+		if errors.As(err, &run) {
+			lastErr[run.Lang] = err
+		}
+	}
+	var failed []string
+	for lang := range procMap {
+		if _, ok := timings[lang]; !ok {
+			failed = append(failed, lang)
+		}
+	}
+	sort.Strings(failed)
+
+	for round := 1; len(timings) < cfg.minLangs && round <= cfg.retryMeasure && ctx.Err() == nil; round++ {
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "Only %d of %d languages finished, re-running %s (%d/%d)...\n",
+				len(timings), len(procMap), strings.Join(failed, ", "), round, cfg.retryMeasure)
+		}
+		var still []string
+		for _, lang := range failed {
+			smp, err := timeTask(ctx, lang, procMap[lang], cfg)
+			cfg.hooks.ran(lang, smp, err)
+			if err != nil {
+				lastErr[lang] = err
+				still = append(still, lang)
+				continue
+			}
+			prog.step("ran " + lang)
+			timings[lang] = smp
+		}
+		failed = still
+	}
+
+	if len(timings) < cfg.minLangs {
+		var left []error
+		for _, lang := range failed {
+			if err := lastErr[lang]; err != nil {
+				left = append(left, err)
+			}
+		}
+		left = append(left, fmt.Errorf("only %d of %d languages finished after %d --retry-measure rounds (--min-langs is %d)",
+			len(timings), len(procMap), cfg.retryMeasure, cfg.minLangs))
+		return nil, errors.Join(left...)
+	}
+	if len(failed) > 0 {
+		warnf("left out after failing: %s", strings.Join(failed, ", "))
+	}
+	return timings, nil
+}
+
+// checkBudget reports the languages --max-runtime cut off and fails if fewer
+// than --min-langs made it.
+func checkBudget(timings map[string]sample, procMap map[string][]string, cfg config) (map[string]sample, error) {
+	if len(timings) == len(procMap) {
+		return timings, nil
+	}
+	if cfg.verbosity >= VerbosityLite {
+		var dropped []string
+		for lang := range procMap {
+			if _, ok := timings[lang]; !ok {
+				dropped = append(dropped, lang)
+			}
+		}
+		sort.Strings(dropped)
+		fmt.Fprintf(diag, "--max-runtime %s reached, skipped: %s\n", cfg.maxRuntime, strings.Join(dropped, ", "))
+	}
+	if len(timings) < cfg.minLangs {
+		return nil, fmt.Errorf("only %d of %d languages finished within --max-runtime %s (--min-langs is %d)",
+			len(timings), len(procMap), cfg.maxRuntime, cfg.minLangs)
+	}
+	return timings, nil
+}
+
+// timingOrder is the byte order --endian picks for the timings in the hash
+// buffer. Everything else in the buffer stays big-endian.
+func timingOrder(cfg config) binary.ByteOrder {
+	if cfg.endian == "little" {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// deriveSeed hashes the timings with blake2b and cuts the digest down to
+// cfg.seedBits, returning both the full digest and the truncated seed bytes.
+// The buffer is hashBuffer's, with the languages in sorted order so the
+// same observations always hash the same way and swapping two languages'
+// timings changes the seed; the README's Hash buffer section has its exact
+// byte order.
+func deriveSeed(timings map[string]int64, cfg config, mix []byte) (hash, raw []byte) {
+	// This deliberately stays a 64-byte blake2b plus truncation rather than
+	// one sized to the seed width. BLAKE2 mixes the digest length into its
+	// parameter block, so a 16-byte blake2b is not a prefix of the 64-byte
+	// one: switching would change every seed below -S 512. The shift is
+	// still needed either way for -S values that aren't a multiple of 8.
+	// With no --key this is exactly blake2b.Sum512.
+	h, err := blake2b.New512(cfg.key)
+	if err != nil {
+		// parseFlags already rejects keys longer than blake2b.Size.
+		panic(err)
+	}
+	h.Write(hashBuffer(timings, cfg, mix))
+	sum := h.Sum(nil)
+	for range cfg.hashRounds - 1 {
+		h.Reset()
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+
+	return sum, truncateHash(sum, cfg.seedBits)
+}
+
+// hashBuffer lays out the bytes deriveSeed hashes in cfg's --buffer-layout,
+// as the README's Hash buffer section spells out: the v2 tag, the salt, the
+// timings in sorted language order (or their --debias bits), then mix. The
+// timings are rounded to --timing-precision first.
+func hashBuffer(timings map[string]int64, cfg config, mix []byte) []byte {
+	timings = hashedTimings(timings, cfg)
+	langs := make([]string, 0, len(timings))
+	for lang := range timings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	buf := new(bytes.Buffer)
+	if cfg.bufferLayout == "v2" {
+		buf.WriteString(layoutV2Tag)
+	}
+	buf.Write(cfg.salt)
+	if cfg.debias {
+		buf.Write(debiasTimings(langs, timings))
+	} else {
+		for _, lang := range langs {
+			var b [8]byte
+			timingOrder(cfg).PutUint64(b[:], uint64(timings[lang]))
+			buf.Write(layoutName(cfg.bufferLayout, lang))
+			for range max(cfg.weights[lang], 1) {
+				buf.Write(b[:])
+			}
+		}
+	}
+	buf.Write(mix)
+	return buf.Bytes()
+}
+
+// truncateHash cuts sum down to its first bits bits: whole leading bytes,
+// with the first byte shifted right when bits isn't a multiple of 8.
+func truncateHash(sum []byte, bits int) []byte {
+	byteLen := (bits + 7) / 8
+	raw := append([]byte(nil), sum[:byteLen]...)
+	if bits%8 != 0 {
+		raw[0] >>= (8 - (bits % 8))
+	}
+	return raw
+}
+
+func main() {
+	cfg := parseFlags()
+	if cfg.quiet || cfg.json {
+		diag = os.Stderr
+	}
+	setupColor(cfg.color)
+	toolBinaries = cfg.toolBinaries
+	failStrict(cfg, nil)
+
+	if cfg.profileSummary {
+		if err := printProfileSummary(cfg.profile); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.reveal != "" {
+		if err := reveal(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.checkDeterminism {
+		if !checkDeterminism(cfg) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var previous *jsonResult
+	if cfg.compare != "" {
+		var err error
+		previous, err = readJSONResult(cfg.compare)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.manifestPath != "" {
+		var err error
+		cfg.manifest, err = readManifest(cfg.manifestPath)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+		for _, l := range cfg.manifest {
+			if cfg.extraCmds[l.Name] != nil {
+				fmt.Fprintf(errOut, "--extra-cmd %s is also defined in %s\n", l.Name, cfg.manifestPath)
+				os.Exit(1)
+			}
+		}
+	}
+
+	for name := range cfg.weights {
+		known := slices.Contains(interpretedLangs(cfg), name) || slices.Contains(compiledLangs(cfg), name) || cfg.extraCmds[name] != nil
+		for _, l := range cfg.manifest {
+			known = known || l.Name == name
+		}
+		if !known && cfg.command != "replay" {
+			fmt.Fprintf(errOut, "--weight %s isn't one of the languages this run times\n", name)
+			os.Exit(1)
+		}
+	}
+
+	for name := range cfg.langTimeouts {
+		known := slices.Contains(interpretedLangs(cfg), name) || slices.Contains(compiledLangs(cfg), name) || cfg.extraCmds[name] != nil
+		for _, lang := range compiledLangs(cfg) {
+			known = known || compileTool(lang, cfg) == name
+		}
+		for _, l := range cfg.manifest {
+			known = known || l.Name == name || len(l.Compile) > 0 && filepath.Base(l.Compile[0]) == name
+		}
+		if !known && cfg.command != "replay" {
+			fmt.Fprintf(errOut, "--timeout-lang %s isn't a language this run times or a compiler it uses\n", name)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.explainChaos != "" {
+		c := cfg
+		c.chaos = cfg.explainChaos
+		explainChaos(os.Stdout, c)
+		return
+	}
+
+	if cfg.dumpSources {
+		dumpSources(os.Stdout, cfg)
+		return
+	}
+
+	if cfg.command == "replay" {
+		res, err := replay(cfg)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+		failStrict(cfg, res)
+		report(cfg, res, previous)
+		saveBundle(cfg, res)
+		execSeed(cfg, res)
+		return
+	}
+
+	if cfg.snapshotTimings != "" {
+		if _, err := os.Stat(cfg.snapshotTimings); err == nil {
+			cfg.replayFile = cfg.snapshotTimings
+			res, err := replay(cfg)
+			if err != nil {
+				fmt.Fprintln(errOut, err)
+				os.Exit(1)
+			}
+			failStrict(cfg, res)
+			report(cfg, res, previous)
+			saveBundle(cfg, res)
+			execSeed(cfg, res)
+			return
+		}
+	}
+
+	tools := preflightLangCheck(cfg)
+	if cfg.fallbackChaos {
+		chaos, kept := fallbackChaos(cfg, tools)
+		if chaos != cfg.chaos {
+			fmt.Fprintf(diag, "Falling back to --chaos %s, missing %s\n", chaos, strings.Join(missingTools(tools), ", "))
+			cfg.chaos, tools = chaos, kept
+		}
+	}
+	toolStatuses = tools
+	for name, t := range tools {
+		if t.Found {
+			toolPaths[name] = t.Path
+		}
+	}
+	var preflightErr error
+	if missing := missingTools(tools); len(missing) > 0 {
+		for i, name := range missing {
+			if b := toolBinary(name); b != name {
+				missing[i] = fmt.Sprintf("%s (%s)", name, b)
+			}
+		}
+		preflightErr = &ErrPreflight{Missing: missing}
+	}
+	if err := checkRequirements(cfg.require, tools); err != nil {
+		preflightErr = errors.Join(preflightErr, err)
+	}
+
+	if cfg.compilerCheck {
+		if err := printToolVersions(os.Stdout, cfg, tools); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+		if preflightErr != nil {
+			os.Exit(exitCode(preflightErr))
+		}
+		return
+	}
+
+	if preflightErr != nil {
+		fmt.Fprintln(errOut, preflightErr)
+		if cfg.json {
+			if err := writePreflightFailure(os.Stdout, tools); err != nil {
+				fmt.Fprintln(errOut, err)
+			}
+		}
+		os.Exit(exitCode(preflightErr))
+	}
+
+	if cfg.verbosity >= VerbosityLite {
+		fmt.Fprintf(diag, "PTRSG %s\n", version)
+		fmt.Fprintf(diag, "Using chaos=%s, queue=%v\n", cfg.chaos, cfg.queue)
+	}
+
+	if cfg.checkCompile {
+		if err := checkCompile(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(exitCode(err))
+		}
+		failStrict(cfg, nil)
+		return
+	}
+
+	if cfg.compileOnly != "" {
+		if err := compileOnly(cfg); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(exitCode(err))
+		}
+		failStrict(cfg, nil)
+		return
+	}
+
+	if cfg.verifyRepro {
+		if err := verifyReproducible(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	if cfg.maxLoad > 0 {
+		if err := checkLoad(cfg); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	}
+
+	// Measured here rather than in parseFlags so --version and friends
+	// don't pay for it.
+	cfg.clockResolution = clockResolution()
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] Clock resolution: %s\n", cfg.clockResolution)
+	}
+	if cfg.clockResolution > coarseClock {
+		warnf("the clock only resolves %s, so timings lose their low bits", cfg.clockResolution)
+	}
+	failStrict(cfg, nil)
+
+	if cfg.command == "selftest" {
+		if !selftest(cfg) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.stream > 0 {
+		if err := stream(cfg, previous); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	res, err := Generate(cfg)
+	for attempt := 1; err == nil && attempt < cfg.assertAttempts; attempt++ {
+		why := rejectResult(res, cfg)
+		if why == "" {
+			break
+		}
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "%s, measuring again (%d/%d)...\n", why, attempt+1, cfg.assertAttempts)
+		}
+		res, err = Generate(cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		os.Exit(exitCode(err))
+	}
+	if why := rejectResult(res, cfg); why != "" {
+		fmt.Fprintln(errOut, why)
+		os.Exit(1)
+	}
+	failStrict(cfg, res)
+
+	if cfg.profile != "" {
+		if err := appendProfile(cfg.profile, cfg.chaos, res.Timings); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.snapshotTimings != "" {
+		if err := writeSnapshot(cfg, res); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	}
+
+	report(cfg, res, previous)
+	saveBundle(cfg, res)
+	execSeed(cfg, res)
+}
+
+// execSeed hands the seed to --exec, if set, and exits with the command's
+// status.
+func execSeed(cfg config, res *Result) {
+	if len(cfg.exec) == 0 {
+		return
+	}
+	code, err := runExec(cfg, res)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+// reportTimings is report for --timings-only: the timings are the output,
+// so they go to stdout whatever the verbosity, as JSON with --json.
+func reportTimings(cfg config, res *Result, previous *jsonResult) {
+	if cfg.json {
+		if err := writeTimingsJSON(os.Stdout, cfg, res); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	} else {
+		printTimings(os.Stdout, res.Timings, cfg.format, cfg.timeUnit)
+	}
+	if cfg.histogram && cfg.verbosity >= VerbosityLite {
+		printHistograms(diag, res.Runs)
+	}
+	if res.Baseline > 0 {
+		printBaseline(diag, res.Timings, res.Baseline)
+	}
+	if previous != nil {
+		printComparison(diag, previous.Timings, res.Timings, cfg.timeUnit)
+	}
+}
+
+// rejectResult says why res fails --assert-bits or --min-spread, or returns
+// "" if it passes both.
+func rejectResult(res *Result, cfg config) string {
+	for _, seed := range res.Seeds {
+		if seed.BitLen() < cfg.assertBits {
+			return fmt.Sprintf("seed has fewer than the %d significant bits --assert-bits requires", cfg.assertBits)
+		}
+	}
+	if cfg.minSpread > 0 {
+		if spread := timingSpread(res.Timings); spread < cfg.minSpread {
+			return fmt.Sprintf("timing spread %s is below --min-spread %s", spread, cfg.minSpread)
+		}
+	}
+	return ""
+}
+
+// timingSpread is the gap between the slowest and fastest timing.
+func timingSpread(timings map[string]int64) time.Duration {
+	first := true
+	var lo, hi int64
+	for _, ns := range timings {
+		if first || ns < lo {
+			lo = ns
+		}
+		if first || ns > hi {
+			hi = ns
+		}
+		first = false
+	}
+	return time.Duration(hi - lo)
+}
+
+// report prints everything about a finished run: timings, stats, the seed
+// in whichever form the flags ask for, payloads and comparisons.
+func report(cfg config, res *Result, previous *jsonResult) {
+	if cfg.dumpBufferOnly {
+		return
+	}
+	if cfg.timingsOnly {
+		reportTimings(cfg, res, previous)
+		return
+	}
+	if cfg.verbosity >= VerbosityLite {
+		printTimings(diag, res.Timings, cfg.format, cfg.timeUnit)
+	}
+	if cfg.histogram && cfg.verbosity >= VerbosityLite {
+		printHistograms(diag, res.Runs)
+	}
+
+	if res.Baseline > 0 {
+		printBaseline(diag, res.Timings, res.Baseline)
+	}
+
+	if res.Degraded {
+		fmt.Fprintf(errOut, "WARNING: low-entropy seed (%d sources)\n", len(res.Timings))
+	}
+	if cfg.stats {
+		printEntropyStats(diag, len(res.Hash)*8, cfg.seedBits, prngBits(cfg.randImpl), res.Seed, res.EntropyBits)
+		printStability(diag, res.Runs)
+	}
+	if cfg.commit != "" {
+		if err := writeCommitment(os.Stdout, cfg, res); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	} else if cfg.rawHash {
+		fmt.Printf("%x\n", res.Hash)
+	} else if cfg.sweep {
+		for _, bits := range sweepBits {
+			raw, err := seedRaw(res.Hash, bits, cfg)
+			if err != nil {
+				fmt.Fprintln(errOut, err)
+				os.Exit(1)
+			}
+			fmt.Println(seedLine(cfg, res, bits, new(big.Int).SetBytes(raw)))
+		}
+	} else if cfg.json {
+		if err := writeJSON(os.Stdout, cfg, res); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	} else if !cfg.quiet {
+		for _, seed := range res.Seeds {
+			fmt.Println(seedLine(cfg, res, cfg.seedBits, seed))
+		}
+	}
+
+	if cfg.verify > 0 {
+		// A fresh generator so --emit-bytes still starts from the
+		// beginning of the stream.
+		r := newPRNG(res.Raw, cfg)
+		for i := 0; i < cfg.verify; i++ {
+			fmt.Println(r.Int63())
+		}
+	}
+
+	if cfg.permute > 0 {
+		// Also a fresh generator, for the same reason.
+		r := newPRNG(res.Raw, cfg)
+		perm := make([]int, cfg.permute)
+		for i := range perm {
+			perm[i] = i
+		}
+		r.Shuffle(len(perm), func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+		for _, v := range perm {
+			fmt.Println(v)
+		}
+	}
+
+	if cfg.emitBytes > 0 || cfg.output != "" {
+		payload := res.Raw
+		if cfg.emitBytes > 0 {
+			payload = make([]byte, cfg.emitBytes)
+			res.Rand.Read(payload)
+		}
+		if err := writePayload(cfg.output, payload); err != nil {
+			fmt.Fprintln(errOut, err)
+			os.Exit(1)
+		}
+	}
+
+	if previous != nil {
+		printComparison(diag, previous.Timings, res.Timings, cfg.timeUnit)
+	}
+
+	if cfg.verbosity == VerbosityHeavy && cfg.commit == "" {
+		printSummary(diag, cfg, res)
+	}
 }
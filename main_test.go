@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClampDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d  time.Duration
+		ns int64
+		ok bool
+	}{
+		{-time.Nanosecond, 0, false},
+		{-time.Hour, 0, false},
+		{0, 0, true},
+		{time.Millisecond, int64(time.Millisecond), true},
+		{maxTaskDuration, int64(maxTaskDuration), true},
+		{maxTaskDuration + 1, int64(maxTaskDuration), false},
+		{1<<63 - 1, int64(maxTaskDuration), false},
+	} {
+		ns, ok := clampDuration(tc.d)
+		if ns != tc.ns || ok != tc.ok {
+			t.Errorf("clampDuration(%d) = %d, %v; want %d, %v", tc.d, ns, ok, tc.ns, tc.ok)
+		}
+	}
+}
+
+// parseFlagsEnv holds the arguments, space-separated, that the test binary
+// re-run by runParseFlags hands to parseFlags. parseFlags exits on a bad
+// flag, so it can only be watched from outside.
+const parseFlagsEnv = "PTRSG_TEST_PARSEFLAGS"
+
+func runParseFlags(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParseFlagsBounds$")
+	cmd.Env = append(os.Environ(), parseFlagsEnv+"="+strings.Join(args, " "))
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestParseFlagsBounds(t *testing.T) {
+	if args, ok := os.LookupEnv(parseFlagsEnv); ok {
+		os.Args = append([]string{"ptrsg"}, strings.Fields(args)...)
+		parseFlags()
+		os.Exit(0)
+	}
+	for _, tc := range []struct {
+		args []string
+		want string // "" if the flags are accepted
+	}{
+		{[]string{"-S", "1"}, ""},
+		{[]string{"-S", "0"}, "-S must be at least 1"},
+		{[]string{"-S", "512"}, ""},
+		{[]string{"-S", "513"}, "-S 513 exceeds blake2b's 512-bit output"},
+		{[]string{"--iterations", "1"}, ""},
+		{[]string{"--iterations", "0"}, "--iterations must be at least 1"},
+		{[]string{"--parallel", "0"}, ""},
+		{[]string{"--parallel", "-1"}, "--parallel must not be negative"},
+		{[]string{"--retries", "-1"}, "--retries must not be negative"},
+		{[]string{"--min-langs", "0"}, "--min-langs must be at least 1"},
+		{[]string{"--seed-count", "0"}, "--seed-count must be at least 1"},
+		{[]string{"--hash-rounds", "0"}, "--hash-rounds must be at least 1"},
+		{[]string{"--emit-bytes", "-1"}, "--emit-bytes must not be negative"},
+		{[]string{"--seed-bytes", "64"}, ""},
+		{[]string{"--seed-bytes", "0"}, "--seed-bytes must be 1-64"},
+		{[]string{"--seed-bytes", "65"}, "--seed-bytes must be 1-64"},
+	} {
+		out, err := runParseFlags(t, tc.args...)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%v: rejected: %v\n%s", tc.args, err, out)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(out, tc.want) {
+			t.Errorf("%v: got %v with output %q, want an error saying %q", tc.args, err, out, tc.want)
+		}
+	}
+}
+
+// testConfig is the config the hashing tests start from: the defaults
+// parseFlags would leave for a 64-bit seed, with nothing optional mixed in.
+func testConfig() config {
+	return config{
+		seedBits:     64,
+		seedCount:    1,
+		hashRounds:   1,
+		bufferLayout: defaultBufferLayout,
+		randImpl:     "v1",
+	}
+}
+
+func TestResultFromSamplesEmpty(t *testing.T) {
+	for _, samples := range []map[string]sample{nil, {}} {
+		res, err := resultFromSamples(samples, testConfig())
+		if err == nil || res != nil {
+			t.Errorf("resultFromSamples(%v) = %v, %v; want an error and no result", samples, res, err)
+		}
+	}
+}
+
+func TestResultFromSamplesSingle(t *testing.T) {
+	cfg := testConfig()
+	ns := int64(5 * time.Millisecond)
+	res, err := resultFromSamples(map[string]sample{"go": {ns: ns}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Timings) != 1 || res.Timings["go"] != ns {
+		t.Errorf("Timings = %v, want just go=%d", res.Timings, ns)
+	}
+	hash, raw := deriveSeed(map[string]int64{"go": ns}, cfg, nil)
+	if !bytes.Equal(res.Hash, hash) || !bytes.Equal(res.Raw, raw) {
+		t.Errorf("hash %x, raw %x; want %x, %x", res.Hash, res.Raw, hash, raw)
+	}
+	if len(res.Seeds) != 1 || res.Seeds[0].Cmp(res.Seed) != 0 {
+		t.Errorf("Seeds = %v, want just %v", res.Seeds, res.Seed)
+	}
+	if !res.Degraded {
+		t.Error("a seed from one language isn't marked degraded")
+	}
+}
+
+func TestHashBufferLayout(t *testing.T) {
+	timings := map[string]int64{"lua": 0x0102030405060708, "c": 0x10}
+	mix := []byte{0xee}
+	cat := func(parts ...string) []byte { return []byte(strings.Join(parts, "")) }
+	for _, tc := range []struct {
+		layout, endian string
+		want           []byte
+	}{
+		{"v1", "big", cat("salt",
+			"c", "\x00\x00\x00\x00\x00\x00\x00\x10",
+			"lua", "\x01\x02\x03\x04\x05\x06\x07\x08",
+			"\xee")},
+		{"v1", "little", cat("salt",
+			"c", "\x10\x00\x00\x00\x00\x00\x00\x00",
+			"lua", "\x08\x07\x06\x05\x04\x03\x02\x01",
+			"\xee")},
+		{"v2", "big", cat("ptrsg/v2", "salt",
+			"\x00\x01c", "\x00\x00\x00\x00\x00\x00\x00\x10",
+			"\x00\x03lua", "\x01\x02\x03\x04\x05\x06\x07\x08",
+			"\xee")},
+		{"v2", "little", cat("ptrsg/v2", "salt",
+			"\x00\x01c", "\x10\x00\x00\x00\x00\x00\x00\x00",
+			"\x00\x03lua", "\x08\x07\x06\x05\x04\x03\x02\x01",
+			"\xee")},
+	} {
+		cfg := testConfig()
+		cfg.bufferLayout, cfg.endian, cfg.salt = tc.layout, tc.endian, []byte("salt")
+		if got := hashBuffer(timings, cfg, mix); !bytes.Equal(got, tc.want) {
+			t.Errorf("%s, %s endian:\n got  %x\n want %x", tc.layout, tc.endian, got, tc.want)
+		}
+	}
+}
+
+func TestTruncateHash(t *testing.T) {
+	sum := bytes.Repeat([]byte{0xff, 0xa5}, hashBits/16)
+	for _, tc := range []struct {
+		bits int
+		want []byte
+	}{
+		// Whole leading bytes, with only the first one shifted down to
+		// the bits left over.
+		{1, []byte{0x01}},
+		{12, []byte{0x0f, 0xa5}},
+		{13, []byte{0x1f, 0xa5}},
+		{511, append([]byte{0x7f}, sum[1:]...)},
+		{512, sum},
+	} {
+		raw := truncateHash(sum, tc.bits)
+		if len(raw) != (tc.bits+7)/8 {
+			t.Errorf("-S %d: %d bytes, want %d", tc.bits, len(raw), (tc.bits+7)/8)
+		}
+		if unused := len(raw)*8 - tc.bits; unused > 0 && raw[0]>>(8-unused) != 0 {
+			t.Errorf("-S %d: first byte %08b has bits above -S set", tc.bits, raw[0])
+		}
+		if !bytes.Equal(raw, tc.want) {
+			t.Errorf("-S %d: %x, want %x", tc.bits, raw, tc.want)
+		}
+	}
+}
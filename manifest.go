@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// manifestLang is one user-defined language from a --manifest file. Source
+// is written to a file with extension Ext; Compile, if set, runs once
+// before timing and Run is the command that gets timed. Both may use the
+// placeholders {src}, {exe} and {dir}.
+type manifestLang struct {
+	Name    string   `json:"name"`
+	Ext     string   `json:"ext"`
+	Source  string   `json:"source"`
+	Compile []string `json:"compile,omitempty"`
+	Run     []string `json:"run"`
+}
+
+// manifestNameRe keeps manifest names safe to use in file names.
+var manifestNameRe = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// builtinLangs are the names a manifest can't reuse, along with the
+// --cpp-compilers variants.
+var builtinLangs = []string{"lua", "python", "node", "php", "perl", "go", "c", "cpp", "rust", "zig", "swift", "haskell"}
+
+// readManifest loads and checks a --manifest file, a JSON array of
+// manifestLang.
+func readManifest(path string) ([]manifestLang, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var langs []manifestLang
+	if err := json.Unmarshal(b, &langs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("%s: no languages defined", path)
+	}
+	seen := make(map[string]bool)
+	for i, l := range langs {
+		switch {
+		case !manifestNameRe.MatchString(l.Name):
+			return nil, fmt.Errorf("%s: entry %d: name %q must be lowercase letters, digits, - or _", path, i, l.Name)
+		case slices.Contains(builtinLangs, l.Name), cppVariant(l.Name) != "":
+			return nil, fmt.Errorf("%s: %s is a built-in language", path, l.Name)
+		case seen[l.Name]:
+			return nil, fmt.Errorf("%s: %s is defined twice", path, l.Name)
+		case len(l.Run) == 0:
+			return nil, fmt.Errorf("%s: %s has no run command", path, l.Name)
+		}
+		seen[l.Name] = true
+	}
+	return langs, nil
+}
+
+// expandCommand fills the manifest placeholders in each argument.
+func expandCommand(args []string, src, exe, dir string) []string {
+	r := strings.NewReplacer("{src}", src, "{exe}", exe, "{dir}", dir)
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = r.Replace(a)
+	}
+	return out
+}
+
+// buildKey identifies what a manifest language's build depends on: its
+// extension, source and compile command before the placeholders are filled
+// in. Languages with the same key compile to the same binary.
+func buildKey(l manifestLang) [32]byte {
+	h, _ := blake2b.New256(nil)
+	for _, part := range append([]string{l.Ext, l.Source}, l.Compile...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	var key [32]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// prepareManifest writes and compiles every manifest language in tmpdir and
+// returns the command to time for each. A language whose buildKey matches
+// one already built gets a hard link to that executable instead of a
+// compile of its own. With --fail-fast=false a failed compile is skipped
+// and reported with the others at the end.
+func prepareManifest(ctx context.Context, tmpdir string, cfg config) (map[string][]string, error) {
+	procs := make(map[string][]string, len(cfg.manifest))
+	built := make(map[[32]byte]manifestLang)
+	var errs []error
+	for _, l := range cfg.manifest {
+		src := filepath.Join(tmpdir, "task_"+l.Name)
+		if l.Ext != "" {
+			src += "." + strings.TrimPrefix(l.Ext, ".")
+		}
+		exe := filepath.Join(tmpdir, "task_"+l.Name+".exe")
+		if err := os.WriteFile(src, []byte(l.Source), sourceMode(cfg)); err != nil {
+			return nil, writeTaskError(l.Name, src, err)
+		}
+		key := buildKey(l)
+		if first, ok := built[key]; ok && len(l.Compile) > 0 {
+			// No {exe} in the compile command leaves nothing to link, and
+			// then it's built like any other. An exe already there is
+			// from an earlier build in the same directory, as under
+			// --verify-reproducible, and gives way.
+			os.Remove(exe)
+			if err := os.Link(filepath.Join(tmpdir, "task_"+first.Name+".exe"), exe); err == nil {
+				if cfg.verbosity == VerbosityHeavy {
+					fmt.Fprintf(diag, "[DEBUG] %s has the same build as %s, reusing it\n", l.Name, first.Name)
+				}
+				prog.step("compiled " + l.Name)
+				cfg.hooks.compiled(l.Name, 0)
+				procs[l.Name] = expandCommand(l.Run, src, exe, tmpdir)
+				continue
+			}
+		}
+		if len(l.Compile) > 0 {
+			args := expandCommand(l.Compile, src, exe, tmpdir)
+			err := withRetries(ctx, cfg, "compiling "+l.Name, func() error {
+				return compileWatchdog(ctx, cfg, filepath.Base(args[0]), func(ctx context.Context) error {
+					cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+					cmd.Dir = tmpdir
+					if cfg.verbosity == VerbosityHeavy {
+						fmt.Fprintf(diag, "[DEBUG] %s compile: %v\n", l.Name, cmd.Args)
+					}
+					printCommand(cfg, cmd)
+					if err := compileTimes.time(l.Name, func() error { return runCompiler(ctx, cmd, cfg) }); err != nil {
+						return err
+					}
+					return chmodBinary(exe, cfg)
+				})
+			})
+			if err != nil {
+				if cfg.failFast {
+					return nil, &ErrCompile{Lang: l.Name, Err: err}
+				}
+				errs = append(errs, &ErrCompile{Lang: l.Name, Err: err})
+				continue
+			}
+			prog.step("compiled " + l.Name)
+			cfg.hooks.compiled(l.Name, compileTimes.duration(l.Name))
+			built[key] = l
+		}
+		procs[l.Name] = expandCommand(l.Run, src, exe, tmpdir)
+	}
+	return procs, errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "slices"
+
+// dropOutliers is --drop-outliers for one language: it drops the pct% of
+// smp's runs with the highest and the pct% with the lowest timings,
+// rounding down, and makes ns the sum of the rest. The runs kept stay in
+// the order they ran, for --fold all.
+func dropOutliers(smp sample, pct int) sample {
+	k := len(smp.runs) * pct / 100
+	if k == 0 {
+		return smp
+	}
+	sorted := slices.Clone(smp.runs)
+	slices.Sort(sorted)
+	lo, hi := sorted[k], sorted[len(sorted)-1-k]
+	// Ties at either cut-off are dropped only as often as needed, so
+	// exactly k go from each end.
+	dropLo, dropHi := 0, 0
+	for _, ns := range sorted[:k] {
+		if ns == lo {
+			dropLo++
+		}
+	}
+	for _, ns := range sorted[len(sorted)-k:] {
+		if ns == hi {
+			dropHi++
+		}
+	}
+	kept := make([]int64, 0, len(smp.runs)-2*k)
+	smp.ns = 0
+	for _, ns := range smp.runs {
+		switch {
+		case ns < lo, ns > hi:
+			continue
+		case ns == lo && dropLo > 0:
+			dropLo--
+			continue
+		case ns == hi && dropHi > 0:
+			dropHi--
+			continue
+		}
+		kept = append(kept, ns)
+		smp.ns += ns
+	}
+	smp.runs = kept
+	return smp
+}
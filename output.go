@@ -0,0 +1,569 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// jsonResult is the document --json writes. Renaming or removing a field,
+// or changing what one means, means bumping schemaVersion; adding one
+// doesn't.
+type jsonResult struct {
+	SchemaVersion  int                `json:"schemaVersion"`
+	Version        string             `json:"version"`
+	Chaos          string             `json:"chaos"`
+	Bits           int                `json:"bits"`
+	BufferLayout   string             `json:"bufferLayout,omitempty"`
+	Timings        map[string]int64   `json:"timings"`
+	TimeUnit       string             `json:"timeUnit,omitempty"`
+	DisplayTimings map[string]string  `json:"displayTimings,omitempty"`
+	ExitCodes      map[string]int     `json:"exitCodes,omitempty"`
+	MaxRSS         map[string]int64   `json:"maxRss,omitempty"`
+	Counters       map[string]int64   `json:"counters,omitempty"`
+	BinSizes       map[string]int64   `json:"binSizes,omitempty"`
+	CtxSwitches    map[string]int64   `json:"ctxSwitches,omitempty"`
+	StderrLengths  map[string]int64   `json:"stderrLengths,omitempty"`
+	StderrDigests  map[string]string  `json:"stderrDigests,omitempty"`
+	CompileTimes   map[string]int64   `json:"compileTimes,omitempty"`
+	Addresses      map[string]int64   `json:"addresses,omitempty"`
+	Beacon         string             `json:"beacon,omitempty"`
+	Runs           map[string][]int64 `json:"runs,omitempty"`
+	BaselineNs     int64              `json:"baselineNs,omitempty"`
+	Hash           string             `json:"hash"`
+	Seed           string             `json:"seed"`
+	Seeds          []string           `json:"seeds,omitempty"`
+	EntropyBits    int                `json:"entropyBits"`
+	Degraded       bool               `json:"degraded,omitempty"`
+	Environment    *jsonEnvironment   `json:"environment,omitempty"`
+}
+
+func writeJSON(w io.Writer, cfg config, res *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONResult(cfg, res))
+}
+
+// newJSONResult is res in the --json schema.
+func newJSONResult(cfg config, res *Result) jsonResult {
+	out := jsonResult{
+		SchemaVersion: schemaVersion,
+		Version:       version,
+		Chaos:         cfg.chaos,
+		Bits:          cfg.seedBits,
+		BufferLayout:  res.BufferLayout,
+		Timings:       res.Timings,
+		ExitCodes:     res.ExitCodes,
+		MaxRSS:        res.MaxRSS,
+		Counters:      res.Counters,
+		BinSizes:      res.BinSizes,
+		CtxSwitches:   res.CtxSwitches,
+		StderrLengths: res.StderrLengths,
+		StderrDigests: res.StderrDigests,
+		CompileTimes:  res.CompileTimes,
+		Addresses:     res.Addresses,
+		Beacon:        hex.EncodeToString(res.Beacon),
+		Runs:          res.Runs,
+		BaselineNs:    res.Baseline,
+		Hash:          hex.EncodeToString(res.Hash),
+		Seed:          formatSeed(res.Seed, cfg),
+		EntropyBits:   res.EntropyBits,
+		Degraded:      res.Degraded,
+	}
+	if cfg.timeUnit != "ns" {
+		out.TimeUnit = cfg.timeUnit
+		out.DisplayTimings = make(map[string]string, len(res.Timings))
+		for lang, ns := range res.Timings {
+			out.DisplayTimings[lang] = formatTiming(ns, cfg.timeUnit)
+		}
+	}
+	if cfg.freeze {
+		out.Environment = freezeEnvironment(cfg)
+	}
+	if len(res.Seeds) > 1 {
+		for _, s := range res.Seeds {
+			out.Seeds = append(out.Seeds, formatSeed(s, cfg))
+		}
+	}
+	return out
+}
+
+// timeUnits are the --time-unit values and how many ns each one holds.
+var timeUnits = map[string]int64{"ns": 1, "us": 1e3, "ms": 1e6}
+
+// formatTiming writes ns in unit for display: whole nanoseconds, or us and
+// ms to three decimals.
+func formatTiming(ns int64, unit string) string {
+	if unit == "ns" {
+		return strconv.FormatInt(ns, 10)
+	}
+	return strconv.FormatFloat(float64(ns)/float64(timeUnits[unit]), 'f', 3, 64)
+}
+
+// writeTimingsJSON is writeJSON for --timings-only: the same fields up to
+// the timings, and none of the seed ones.
+func writeTimingsJSON(w io.Writer, cfg config, res *Result) error {
+	out := struct {
+		SchemaVersion  int                `json:"schemaVersion"`
+		Version        string             `json:"version"`
+		Chaos          string             `json:"chaos"`
+		Timings        map[string]int64   `json:"timings"`
+		TimeUnit       string             `json:"timeUnit,omitempty"`
+		DisplayTimings map[string]string  `json:"displayTimings,omitempty"`
+		Runs           map[string][]int64 `json:"runs,omitempty"`
+		BaselineNs     int64              `json:"baselineNs,omitempty"`
+		Environment    *jsonEnvironment   `json:"environment,omitempty"`
+	}{
+		SchemaVersion: schemaVersion,
+		Version:       version,
+		Chaos:         cfg.chaos,
+		Timings:       res.Timings,
+		Runs:          res.Runs,
+		BaselineNs:    res.Baseline,
+	}
+	if cfg.timeUnit != "ns" {
+		out.TimeUnit = cfg.timeUnit
+		out.DisplayTimings = make(map[string]string, len(res.Timings))
+		for lang, ns := range res.Timings {
+			out.DisplayTimings[lang] = formatTiming(ns, cfg.timeUnit)
+		}
+	}
+	if cfg.freeze {
+		out.Environment = freezeEnvironment(cfg)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// printTimings writes the per-language timings in unit, plain as an
+// indented list in language order, or table as aligned columns with each
+// timing's share of the total, slowest first.
+func printTimings(w io.Writer, timings map[string]int64, format, unit string) {
+	langs := make([]string, 0, len(timings))
+	var total int64
+	for lang, ns := range timings {
+		langs = append(langs, lang)
+		total += ns
+	}
+	sort.Strings(langs)
+
+	if format != "table" {
+		fmt.Fprintf(w, "Timings (%s):\n", unit)
+		for _, lang := range langs {
+			fmt.Fprintf(w, "  %s: %s\n", paintLang(lang), formatTiming(timings[lang], unit))
+		}
+		return
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return timings[langs[i]] > timings[langs[j]] })
+	fmt.Fprintln(w, "Timings:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "language\t%s\tshare\t\n", unit)
+	for _, lang := range langs {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(timings[lang]) / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t\n", lang, formatTiming(timings[lang], unit), share)
+	}
+	tw.Flush()
+}
+
+// histogramBins and histogramWidth shape the --histogram bars.
+const (
+	histogramBins  = 8
+	histogramWidth = 40
+)
+
+// printHistograms draws an ASCII histogram of each language's per-iteration
+// timings, binned evenly between its fastest and slowest run.
+func printHistograms(w io.Writer, runs map[string][]int64) {
+	langs := make([]string, 0, len(runs))
+	for lang := range runs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		ts := runs[lang]
+		lo, hi := slices.Min(ts), slices.Max(ts)
+		fmt.Fprintf(w, "%s (n=%d, %s to %s):\n", paintLang(lang), len(ts), time.Duration(lo), time.Duration(hi))
+		var counts [histogramBins]int
+		span := hi - lo + 1
+		for _, t := range ts {
+			counts[int((t-lo)*histogramBins/span)]++
+		}
+		peak := slices.Max(counts[:])
+		for i, c := range counts {
+			from := lo + span*int64(i)/histogramBins
+			bar := strings.Repeat("#", c*histogramWidth/peak)
+			fmt.Fprintf(w, "  %12s | %-*s %d\n", time.Duration(from), histogramWidth, bar, c)
+		}
+	}
+}
+
+// printEntropyStats reports how much of the hash made it into each stage.
+func printEntropyStats(w io.Writer, hashBits, seedBits, prngSeedBits int, seed *big.Int, estimate int) {
+	fmt.Fprintln(w, "Entropy accounting:")
+	fmt.Fprintf(w, "  hash bits:        %d\n", hashBits)
+	fmt.Fprintf(w, "  kept after -S:    %d\n", seedBits)
+	fmt.Fprintf(w, "  significant bits: %d\n", seed.BitLen())
+	fmt.Fprintf(w, "  used by PRNG:     %d\n", min(seedBits, prngSeedBits))
+	fmt.Fprintf(w, "  estimated:        %d\n", estimate)
+}
+
+// stableCV and noisyCV are the mean coefficients of variation below which
+// printStability calls a setup stable, and above which it calls it noisy.
+const (
+	stableCV = 0.01
+	noisyCV  = 0.05
+)
+
+// coefficientOfVariation is the sample standard deviation of runs over
+// their mean.
+func coefficientOfVariation(runs []int64) float64 {
+	var mean float64
+	for _, r := range runs {
+		mean += float64(r)
+	}
+	mean /= float64(len(runs))
+	if mean == 0 {
+		return 0
+	}
+	var sq float64
+	for _, r := range runs {
+		d := float64(r) - mean
+		sq += d * d
+	}
+	return math.Sqrt(sq/float64(len(runs)-1)) / mean
+}
+
+// printStability writes each language's coefficient of variation across its
+// --iterations runs and their mean as an overall score. A high CV makes a
+// good entropy source and a poor benchmark, and a low one the reverse.
+func printStability(w io.Writer, runs map[string][]int64) {
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "  stability:        needs --iterations 2 or more")
+		return
+	}
+	langs := make([]string, 0, len(runs))
+	for lang := range runs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	fmt.Fprintln(w, "Stability (coefficient of variation):")
+	var total float64
+	for _, lang := range langs {
+		cv := coefficientOfVariation(runs[lang])
+		total += cv
+		fmt.Fprintf(w, "  %s: %.2f%%\n", lang, cv*100)
+	}
+	overall := total / float64(len(langs))
+	verdict := "in between"
+	switch {
+	case overall < stableCV:
+		verdict = "stable: good for benchmarking, weak for seeds"
+	case overall > noisyCV:
+		verdict = "noisy: good for seeds, poor for benchmarking"
+	}
+	fmt.Fprintf(w, "  overall: %.2f%% (%s)\n", overall*100, verdict)
+}
+
+// readJSONResult loads a document previously written by --json.
+func readJSONResult(path string) (*jsonResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res jsonResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &res, nil
+}
+
+// printComparison prints the per-language change from prev to cur, with
+// any raw timings in unit.
+func printComparison(w io.Writer, prev, cur map[string]int64, unit string) {
+	seen := make(map[string]bool)
+	for k := range prev {
+		seen[k] = true
+	}
+	for k := range cur {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "Change since previous run:")
+	for _, k := range keys {
+		old, hadOld := prev[k]
+		now, hasNow := cur[k]
+		switch {
+		case !hadOld:
+			fmt.Fprintf(w, "  %s: new (%s)\n", k, formatTiming(now, unit))
+		case !hasNow:
+			fmt.Fprintf(w, "  %s: not run\n", k)
+		case old == 0:
+			fmt.Fprintf(w, "  %s: %s -> %s\n", k, formatTiming(old, unit), formatTiming(now, unit))
+		default:
+			fmt.Fprintf(w, "  %s: %+.1f%%\n", k, float64(now-old)/float64(old)*100)
+		}
+	}
+}
+
+// dumpBuffer writes the --dump-buffer bytes to path, replacing whatever
+// an earlier run (or --stream round) left there.
+func dumpBuffer(path string, buf []byte) error {
+	if err := os.WriteFile(path, buf, 0600); err != nil {
+		return fmt.Errorf("--dump-buffer: %w", err)
+	}
+	return nil
+}
+
+// writePayload writes data to path, or to stdout when path is empty. A
+// unix:// path connects to that Unix socket and a fifo:// path opens an
+// existing named pipe, which blocks until something reads from it.
+func writePayload(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if sock, ok := strings.CutPrefix(path, "unix://"); ok {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return fmt.Errorf("--output: can't connect to %s (is anything listening?): %w", sock, err)
+		}
+		if _, err := conn.Write(data); err != nil {
+			conn.Close()
+			return fmt.Errorf("--output: writing to %s: %w", sock, err)
+		}
+		return conn.Close()
+	}
+	if fifo, ok := strings.CutPrefix(path, "fifo://"); ok {
+		if fi, err := os.Stat(fifo); err == nil && fi.Mode().IsRegular() {
+			return fmt.Errorf("--output: %s is a regular file, not a named pipe", fifo)
+		}
+		// No O_CREATE: a missing pipe is an error, not a new regular file.
+		f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("--output: can't open %s: %w", fifo, err)
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return fmt.Errorf("--output: writing to %s: %w", fifo, err)
+		}
+		return f.Close()
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// jsonTool is one entry of the --compiler-check --json tool list.
+type jsonTool struct {
+	Name    string `json:"name"`
+	Found   bool   `json:"found"`
+	Version string `json:"version"`
+	Family  string `json:"family,omitempty"`
+	Path    string `json:"path"`
+	Error   string `json:"error,omitempty"`
+}
+
+// printToolVersions lists what preflight found, as a table or as JSON.
+func printToolVersions(w io.Writer, cfg config, tools map[string]toolStatus) error {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if cfg.json {
+		list := make([]jsonTool, 0, len(names))
+		for _, name := range names {
+			list = append(list, jsonTool{Name: name, Found: tools[name].Found, Version: tools[name].Version, Family: tools[name].Family, Path: tools[name].Path, Error: tools[name].Err})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			SchemaVersion int        `json:"schemaVersion"`
+			Tools         []jsonTool `json:"tools"`
+		}{schemaVersion, list})
+	}
+
+	for _, name := range names {
+		v := tools[name].Version
+		switch {
+		case !tools[name].Found:
+			v = "missing"
+		case v == "":
+			v = "?"
+		}
+		path := tools[name].Path
+		if f := tools[name].Family; f != "" {
+			path += " (" + f + ")"
+		}
+		fmt.Fprintf(w, "  %-8s %-10s %s\n", name, v, path)
+	}
+	return nil
+}
+
+// jsonMissingTool is one entry of the --json preflight failure report.
+// Binary is what was looked up, which differs from Name under --cxx and
+// friends.
+type jsonMissingTool struct {
+	Name   string `json:"name"`
+	Binary string `json:"binary"`
+	Error  string `json:"error"`
+}
+
+// writePreflightFailure is the --json counterpart of ErrPreflight: every
+// tool preflight couldn't use, with the probe error, so scripts can install
+// exactly what's missing.
+func writePreflightFailure(w io.Writer, tools map[string]toolStatus) error {
+	missing := missingTools(tools)
+	list := make([]jsonMissingTool, 0, len(missing))
+	for _, name := range missing {
+		list = append(list, jsonMissingTool{Name: name, Binary: toolBinary(name), Error: tools[name].Err})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		Error         string            `json:"error"`
+		Missing       []jsonMissingTool `json:"missing"`
+	}{schemaVersion, "preflight", list})
+}
+
+// seedFormats lists the --seed-format values.
+var seedFormats = []string{"decimal", "hex", "uuid"}
+
+// formatSeed renders seed the way --seed-format asks. uuid takes the first
+// 16 bytes of the seed and stamps the RFC 4122 version 4 and variant bits on
+// them, so it needs -S of at least 128.
+func formatSeed(seed *big.Int, cfg config) string {
+	switch cfg.seedFormat {
+	case "hex":
+		// Padded to the full -S width so every seed is the same length.
+		digits := fmt.Sprintf("%0*s", (cfg.seedBits+3)/4, seed.Text(16))
+		if cfg.hexPrefix {
+			return "0x" + digits
+		}
+		return digits
+	case "uuid":
+		b := seed.FillBytes(make([]byte, (cfg.seedBits+7)/8))[:16]
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	}
+	return seed.String()
+}
+
+// seedLine is the line printed for one seed of width bits: the usual
+// "Seed generated" line, or --template with its placeholders filled in.
+func seedLine(cfg config, res *Result, bits int, seed *big.Int) string {
+	if cfg.template == "" {
+		return fmt.Sprintf("Seed generated (%d-bit): %s", bits, formatSeed(seed, cfg))
+	}
+	return strings.NewReplacer(
+		"{seed}", formatSeed(seed, cfg),
+		"{bits}", strconv.Itoa(bits),
+		"{chaos}", cfg.chaos,
+		"{hash}", hex.EncodeToString(res.Hash),
+	).Replace(cfg.template)
+}
+
+// printSummary writes the heavy-mode tail: timings, the full hash and the
+// seeds between fixed markers, one key=value per line, so scripts can find
+// them without wading through the [DEBUG] lines.
+func printSummary(w io.Writer, cfg config, res *Result) {
+	langs := make([]string, 0, len(res.Timings))
+	for lang := range res.Timings {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	fmt.Fprintln(w, "=== SUMMARY ===")
+	for _, lang := range langs {
+		fmt.Fprintf(w, "timing.%s=%d\n", lang, res.Timings[lang])
+	}
+	fmt.Fprintf(w, "hash=%x\n", res.Hash)
+	for i, seed := range res.Seeds {
+		fmt.Fprintf(w, "seed.%d=%s\n", i, formatSeed(seed, cfg))
+	}
+	fmt.Fprintln(w, "=== END SUMMARY ===")
+}
+
+// dumpSources writes every task ptrsg would run for cfg, exactly as it would
+// be written to disk, each under a === name === header. --extra-cmd programs
+// aren't ours to print, so they just show their command.
+func dumpSources(w io.Writer, cfg config) {
+	for _, lang := range interpretedLangs(cfg) {
+		code := codeMap[cfg.workload][lang]
+		if cfg.excludeStartup {
+			code = selfTimed(lang, code)
+		}
+		if cfg.mixASLR {
+			code = addrPrinting(lang, code)
+		}
+		fmt.Fprintf(w, "=== %s ===\n%s\n", lang, code)
+	}
+	for _, lang := range compiledLangs(cfg) {
+		fmt.Fprintf(w, "=== %s ===\n%s\n", lang, compiledSource(lang, cfg))
+	}
+	for _, l := range cfg.manifest {
+		fmt.Fprintf(w, "=== %s ===\n%s\n", l.Name, l.Source)
+	}
+	names := make([]string, 0, len(cfg.extraCmds))
+	for name := range cfg.extraCmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "=== %s ===\ncommand: %s\n\n", name, strings.Join(cfg.extraCmds[name], " "))
+	}
+}
+
+// explainChaos lists what a run with cfg would time, straight from the same
+// language selection Generate uses: the interpreted tasks, the compiled ones
+// with the tool that builds each, then any manifest and --extra-cmd
+// languages.
+func explainChaos(w io.Writer, cfg config) {
+	fmt.Fprintf(w, "Chaos %s runs:\n", cfg.chaos)
+	interpreted := strings.Join(interpretedLangs(cfg), ", ")
+	if interpreted == "" {
+		interpreted = "none"
+	}
+	fmt.Fprintf(w, "  interpreted: %s\n", interpreted)
+	var compiled []string
+	for _, lang := range compiledLangs(cfg) {
+		compiled = append(compiled, fmt.Sprintf("%s (built with %s)", lang, toolBinary(compileTool(lang, cfg))))
+	}
+	fmt.Fprintf(w, "  compiled:    %s\n", strings.Join(compiled, ", "))
+	for _, l := range cfg.manifest {
+		step := "run as is"
+		if len(l.Compile) > 0 {
+			step = "built with " + l.Compile[0]
+		}
+		fmt.Fprintf(w, "  manifest:    %s (%s)\n", l.Name, step)
+	}
+	names := make([]string, 0, len(cfg.extraCmds))
+	for name := range cfg.extraCmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  extra-cmd:   %s\n", name)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatSeedHexPadding(t *testing.T) {
+	for _, tc := range []struct {
+		raw    []byte
+		bits   int
+		prefix bool
+		want   string
+	}{
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0xab}, 64, false, "00000000000000ab"},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0xab}, 64, true, "0x00000000000000ab"},
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0}, 64, false, "0000000000000000"},
+		{[]byte{0, 1}, 12, false, "001"},
+		{[]byte{0x0f, 0xff}, 12, false, "fff"},
+		{[]byte{0}, 1, false, "0"},
+		{[]byte{0, 0x12}, 13, true, "0x0012"},
+	} {
+		cfg := config{seedFormat: "hex", seedBits: tc.bits, hexPrefix: tc.prefix}
+		if got := formatSeed(new(big.Int).SetBytes(tc.raw), cfg); got != tc.want {
+			t.Errorf("formatSeed(%x) at -S %d = %q, want %q", tc.raw, tc.bits, got, tc.want)
+		}
+	}
+}
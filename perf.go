@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// perfArgs wraps cmdArgs in perf stat counting event, with CSV (-x,) output
+// so parsePerfCounter doesn't have to deal with locale-formatted numbers.
+func perfArgs(event string, cmdArgs []string) []string {
+	args := []string{toolPath("perf"), "stat", "-x,", "-e", event, "--"}
+	return append(args, cmdArgs...)
+}
+
+// parsePerfCounter pulls event's count out of perf stat's CSV on stderr. The
+// lines are value,unit,event,...; perf may suffix the event with a modifier
+// like :u, and writes <not counted> or <not supported> when it couldn't
+// read the counter, which is reported as not found.
+func parsePerfCounter(stderr []byte, event string) (int64, bool) {
+	sc := bufio.NewScanner(bytes.NewReader(stderr))
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		name, _, _ := strings.Cut(fields[2], ":")
+		if name != event {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// persistentDone is the line a persistent driver prints after each run of
+// the task.
+const persistentDone = "ptrsg-done"
+
+// persistentDrivers load the task file named by their last argument once,
+// then run it again for every line on stdin and answer each with
+// persistentDone. Each run gets fresh globals, so the snippets don't trip
+// over their own declarations; node's still get require, which the io
+// workload needs. Languages missing here keep spawning a process per
+// iteration under --persistent.
+var persistentDrivers = map[string]string{
+	"lua": `local f = assert(loadfile(arg[1]))
+for _ in io.lines() do
+    f()
+    io.write("` + persistentDone + `\n")
+    io.flush()
+end
+`,
+	"python": `import sys
+with open(sys.argv[1]) as f:
+    code = compile(f.read(), sys.argv[1], "exec")
+while sys.stdin.readline():
+    exec(code, {})
+    sys.stdout.write("` + persistentDone + `\n")
+    sys.stdout.flush()
+`,
+	"node": `const vm = require('vm');
+const script = new vm.Script(require('fs').readFileSync(process.argv[2], 'utf8'));
+require('readline').createInterface({input: process.stdin}).on('line', () => {
+    script.runInNewContext({require});
+    process.stdout.write('` + persistentDone + `\n');
+});
+`,
+}
+
+// writePersistentDriver writes lang's driver next to the task at path and
+// returns the driver's path.
+func writePersistentDriver(lang, path string, mode os.FileMode) (string, error) {
+	driver := filepath.Join(filepath.Dir(path), "driver_"+filepath.Base(path))
+	if err := os.WriteFile(driver, []byte(persistentDrivers[lang]), mode); err != nil {
+		return "", writeTaskError(lang, driver, err)
+	}
+	return driver, nil
+}
+
+// timePersistent is timeTask for a task running under its persistent
+// driver: one process, --iterations round trips over stdin, each timed from
+// the request going out to the answer coming back, so interpreter startup
+// never lands in a timing. --retries doesn't apply; a failure anywhere,
+// including a round trip over --timeout-lang, fails the task.
+func timePersistent(ctx context.Context, lang string, cmdArgs []string, cfg config) (sample, error) {
+	if cfg.verbosity == VerbosityHeavy {
+		fmt.Fprintf(diag, "[DEBUG] Running persistently: %v\n", cmdArgs)
+	}
+	done, err := joinTaskGroup(ctx)
+	if err != nil {
+		return sample{}, err
+	}
+	defer done()
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	if cfg.taskThreads > 0 {
+		cmd.Env = taskEnv(cfg.taskThreads)
+	}
+	if cfg.verbosity == VerbosityHeavy {
+		cmd.Stderr = os.Stderr
+	}
+	var collected stderrCapture
+	if cfg.collectStderr {
+		if cmd.Stderr == nil {
+			cmd.Stderr = &collected
+		} else {
+			cmd.Stderr = io.MultiWriter(cmd.Stderr, &collected)
+		}
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return sample{}, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return sample{}, err
+	}
+	printCommand(cfg, cmd)
+	if err := cmd.Start(); err != nil {
+		return sample{}, &ErrRun{Lang: lang, ExitCode: -1, Err: err}
+	}
+
+	// --timeout-lang limits each round trip, killing the driver if one
+	// goes over.
+	limit := cfg.langTimeouts[lang]
+	var timedOut atomic.Bool
+	var total sample
+	out := bufio.NewReader(stdout)
+	for i := 0; i < max(cfg.iterations, 1); i++ {
+		var timer *time.Timer
+		if limit > 0 {
+			timer = time.AfterFunc(limit, func() {
+				timedOut.Store(true)
+				cmd.Process.Kill()
+			})
+		}
+		start := time.Now()
+		if _, err = io.WriteString(stdin, "run\n"); err != nil {
+			break
+		}
+		var line string
+		line, err = out.ReadString('\n')
+		d := time.Since(start)
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			break
+		}
+		if strings.TrimSpace(line) != persistentDone {
+			err = fmt.Errorf("unexpected output from the driver: %q", line)
+			break
+		}
+		elapsed, _ := clampDuration(d)
+		total.ns += elapsed
+		total.runs = append(total.runs, elapsed)
+	}
+	stdin.Close()
+	if err != nil {
+		cmd.Process.Kill()
+	}
+	if waitErr := cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	if ctx.Err() != nil {
+		return sample{}, ctx.Err()
+	}
+	if timedOut.Load() {
+		return sample{}, &ErrRun{Lang: lang, ExitCode: -1, Err: fmt.Errorf("timed out, killed after %s", limit)}
+	}
+	if err != nil {
+		code := -1
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+			code = cmd.ProcessState.ExitCode()
+		}
+		return sample{}, &ErrRun{Lang: lang, ExitCode: code, Err: err}
+	}
+	if cfg.measureMemory {
+		total.maxRSS = maxRSS(cmd.ProcessState)
+	}
+	if cfg.mixCtxsw {
+		total.ctxsw = ctxSwitches(cmd.ProcessState)
+	}
+	total.stderrLen, total.stderr = collected.n, collected.buf
+	return total, nil
+}
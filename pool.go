@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// poolTailSize is how many bytes from the end of the --pool file get mixed
+// into the hash, one full blake2b-512 digest's worth.
+const poolTailSize = 64
+
+// readPoolTail returns the last poolTailSize bytes of the pool at path, or
+// fewer if the pool is shorter. A missing pool reads as empty.
+func readPoolTail(path string) ([]byte, error) {
+	if isGzip(path) {
+		return readGzipPoolTail(path)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	n := min(info.Size(), poolTailSize)
+	tail := make([]byte, n)
+	if _, err := f.ReadAt(tail, info.Size()-n); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// readGzipPoolTail is readPoolTail for a .gz pool. There's no seeking in a
+// gzip stream, so the whole pool is decompressed to find its tail.
+func readGzipPoolTail(path string) ([]byte, error) {
+	r, err := openRead(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var tail []byte
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if len(tail) > poolTailSize {
+			tail = append(tail[:0], tail[len(tail)-poolTailSize:]...)
+		}
+		if err == io.EOF {
+			return tail, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// appendPool adds the seed bytes of this run to the end of the pool.
+func appendPool(path string, raw []byte) error {
+	f, err := openAppend(path, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
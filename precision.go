@@ -0,0 +1,25 @@
+package main
+
+// timingPrecisions maps each --timing-precision to its step in ns.
+var timingPrecisions = map[string]int64{"ns": 1, "10ns": 10, "100ns": 100, "us": 1000}
+
+// roundTiming rounds ns to the nearest multiple of step, half up.
+func roundTiming(ns, step int64) int64 {
+	if step <= 1 {
+		return ns
+	}
+	return (ns + step/2) / step * step
+}
+
+// hashedTimings is timings as they go into the hash buffer: rounded to
+// --timing-precision. At the default ns precision it's timings itself.
+func hashedTimings(timings map[string]int64, cfg config) map[string]int64 {
+	if cfg.timingPrecision <= 1 {
+		return timings
+	}
+	out := make(map[string]int64, len(timings))
+	for lang, ns := range timings {
+		out[lang] = roundTiming(ns, cfg.timingPrecision)
+	}
+	return out
+}
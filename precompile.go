@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// bytecodeCompilers turn an interpreted task's source into bytecode its
+// interpreter can run directly, returning the command that does it.
+var bytecodeCompilers = map[string]func(src, out string) []string{
+	"python": func(src, out string) []string {
+		code := fmt.Sprintf("import py_compile; py_compile.compile(%q, cfile=%q, doraise=True)", src, out)
+		return []string{toolPath("python"), "-c", code}
+	},
+	"lua": func(src, out string) []string {
+		return []string{toolPath("luac"), "-o", out, src}
+	},
+}
+
+// precompile swaps each task in paths that has a bytecode compiler for its
+// compiled form, so --precompile times execution without parsing. Languages
+// whose compiler is missing or fails keep running from source.
+func precompile(ctx context.Context, paths map[string]string, cfg config) map[string]string {
+	out := make(map[string]string, len(paths))
+	for lang, src := range paths {
+		out[lang] = src
+		compile, ok := bytecodeCompilers[lang]
+		if !ok {
+			continue
+		}
+		bc := src + "c" // task.pyc, task.luac
+		args := compile(src, bc)
+		if _, err := exec.LookPath(args[0]); err != nil {
+			if cfg.verbosity >= VerbosityLite {
+				fmt.Fprintf(diag, "%s not found, running %s from source\n", args[0], lang)
+			}
+			continue
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		if cfg.verbosity == VerbosityHeavy {
+			fmt.Fprintf(diag, "[DEBUG] %s precompile: %v\n", lang, cmd.Args)
+		}
+		printCommand(cfg, cmd)
+		if b, err := runPrecompile(ctx, cmd); err != nil {
+			if cfg.verbosity >= VerbosityLite {
+				fmt.Fprintf(diag, "precompiling %s failed (%v), running from source\n", lang, err)
+			}
+			if cfg.verbosity == VerbosityHeavy {
+				diag.Write(b)
+			}
+			continue
+		}
+		out[lang] = bc
+	}
+	return out
+}
+
+// runPrecompile runs a bytecode compiler under ctx's task group, returning
+// its combined output.
+func runPrecompile(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	done, err := joinTaskGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// printedCommands holds every line --print-command has shown, so a task
+// timed over many iterations is only printed once.
+var printedCommands sync.Map
+
+// printCommand is --print-command: it writes cmd to stderr as a line a
+// POSIX shell would run the same way, prefixed with "+ " like set -x.
+func printCommand(cfg config, cmd *exec.Cmd) {
+	if !cfg.printCommand {
+		return
+	}
+	line := commandLine(cmd)
+	if _, seen := printedCommands.LoadOrStore(line, true); seen {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "+ "+line)
+}
+
+// commandLine renders cmd for a shell: the environment variables it adds
+// to ptrsg's own, a cd into its directory if it has one, and every argument
+// quoted where needed.
+func commandLine(cmd *exec.Cmd) string {
+	var parts []string
+	if cmd.Dir != "" {
+		parts = append(parts, "cd", shellQuote(cmd.Dir), "&&")
+	}
+	if cmd.Env != nil {
+		inherited := os.Environ()
+		for _, kv := range cmd.Env {
+			if !slices.Contains(inherited, kv) {
+				name, value, _ := strings.Cut(kv, "=")
+				parts = append(parts, name+"="+shellQuote(value))
+			}
+		}
+	}
+	for _, arg := range cmd.Args {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellSafe matches arguments a shell passes through untouched.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote single-quotes s unless it's shellSafe, closing and reopening
+// the quotes around any ' inside it.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
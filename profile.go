@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// profileRecord is one line of a --profile history file.
+type profileRecord struct {
+	Time    time.Time        `json:"time"`
+	Chaos   string           `json:"chaos"`
+	Timings map[string]int64 `json:"timings"`
+}
+
+// appendProfile adds this run's timings to the JSONL history at path,
+// creating the file if needed. A .gz path is gzip-compressed, one member per
+// run.
+func appendProfile(path, chaos string, timings map[string]int64) error {
+	f, err := openAppend(path, 0644)
+	if err != nil {
+		return err
+	}
+	rec := profileRecord{Time: time.Now().UTC(), Chaos: chaos, Timings: timings}
+	if err := json.NewEncoder(f).Encode(rec); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readProfile(path string) ([]profileRecord, error) {
+	f, err := openRead(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recs []profileRecord
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec profileRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+func mean(xs []int64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += float64(x)
+	}
+	return sum / float64(len(xs))
+}
+
+// printProfileSummary prints per-language stats for the history at path.
+// The trend compares the mean of the newer half of the runs to the older
+// half, so a positive number means the machine has been getting slower.
+func printProfileSummary(path string) error {
+	recs, err := readProfile(path)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		return fmt.Errorf("%s has no recorded runs", path)
+	}
+
+	series := make(map[string][]int64)
+	for _, rec := range recs {
+		for lang, t := range rec.Timings {
+			series[lang] = append(series[lang], t)
+		}
+	}
+	langs := make([]string, 0, len(series))
+	for lang := range series {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	fmt.Printf("%d runs from %s to %s\n", len(recs),
+		recs[0].Time.Format(time.RFC3339), recs[len(recs)-1].Time.Format(time.RFC3339))
+	for _, lang := range langs {
+		xs := series[lang]
+		lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+		for _, x := range xs {
+			lo = min(lo, x)
+			hi = max(hi, x)
+		}
+		fmt.Printf("  %s: n=%d mean=%.0f min=%d max=%d", lang, len(xs), mean(xs), lo, hi)
+		if len(xs) >= 2 {
+			half := len(xs) / 2
+			older, newer := mean(xs[:half]), mean(xs[len(xs)-half:])
+			if older > 0 {
+				fmt.Printf(" trend=%+.1f%%", (newer-older)/older*100)
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// progress counts finished compile and run steps under lite verbosity. In
+// parallel mode on a terminal it redraws one status line; otherwise it
+// prints a line per step. A nil *progress does nothing.
+type progress struct {
+	mu        sync.Mutex
+	w         io.Writer
+	done      int
+	total     int
+	overwrite bool
+}
+
+// prog is the progress of the Generate call in flight, if any.
+var prog *progress
+
+func newProgress(total int, cfg config) *progress {
+	if cfg.verbosity != VerbosityLite || total == 0 {
+		return nil
+	}
+	return &progress{w: diag, total: total, overwrite: !cfg.queue && isTerminal(diag)}
+}
+
+// step records one finished step, described like "compiled rust".
+func (p *progress) step(what string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.overwrite {
+		fmt.Fprintf(p.w, "\r\033[K[%d/%d] %s", p.done, p.total, what)
+		return
+	}
+	fmt.Fprintf(p.w, "[%d/%d] %s\n", p.done, p.total, what)
+}
+
+// finish ends the status line so later output starts on a fresh one.
+func (p *progress) finish() {
+	if p == nil || !p.overwrite {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.w)
+}
+
+// isTerminal reports whether w is a character device, i.e. a terminal
+// rather than a file or pipe.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
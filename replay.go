@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+)
+
+// replay rederives a seed from a --json result file without measuring
+// anything. Everything from the file that deriveSeed used is mixed back in
+// the original order; the pool tail and clock jitter weren't recorded, so
+// those runs can't be reproduced and replay says so when the hash differs.
+func replay(cfg config) (*Result, error) {
+	prev, err := readJSONResult(cfg.replayFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(prev.Timings) == 0 {
+		return nil, errors.New(cfg.replayFile + ": no timings to replay")
+	}
+	if cfg.bufferLayout == "" {
+		cfg.bufferLayout = cmp.Or(prev.BufferLayout, defaultBufferLayout)
+		if !slices.Contains(bufferLayouts, cfg.bufferLayout) {
+			return nil, fmt.Errorf("%s: recorded with buffer layout %s, which this version doesn't know", cfg.replayFile, cfg.bufferLayout)
+		}
+	}
+
+	var mix []byte
+	if cfg.fold == "all" {
+		if len(prev.Runs) == 0 {
+			return nil, errors.New(cfg.replayFile + ": --fold all needs the per-run timings, and none were recorded")
+		}
+		mix = runBytes(prev.Runs, cfg)
+	}
+	if len(prev.ExitCodes) > 0 {
+		mix = append(mix, exitCodeBytes(prev.ExitCodes)...)
+	}
+	if len(prev.MaxRSS) > 0 {
+		mix = append(mix, int64Mix(prev.MaxRSS)...)
+	}
+	if len(prev.Counters) > 0 {
+		mix = append(mix, int64Mix(prev.Counters)...)
+	}
+	if len(prev.BinSizes) > 0 {
+		mix = append(mix, int64Mix(prev.BinSizes)...)
+	}
+	if len(prev.CtxSwitches) > 0 {
+		mix = append(mix, int64Mix(prev.CtxSwitches)...)
+	}
+	if len(prev.StderrLengths) > 0 {
+		mix = append(mix, stderrBytes(prev.StderrLengths, prev.StderrDigests)...)
+	}
+	if len(prev.CompileTimes) > 0 {
+		mix = append(mix, int64Mix(prev.CompileTimes)...)
+	}
+	if len(prev.Addresses) > 0 {
+		mix = append(mix, int64Mix(prev.Addresses)...)
+	}
+	beacon, err := hex.DecodeString(prev.Beacon)
+	if err != nil {
+		return nil, fmt.Errorf("%s: beacon: %w", cfg.replayFile, err)
+	}
+	mix = append(mix, beacon...)
+
+	if cfg.dumpBuffer != "" {
+		if err := dumpBuffer(cfg.dumpBuffer, hashBuffer(prev.Timings, cfg, counterMix(mix, cfg, 0))); err != nil {
+			return nil, err
+		}
+	}
+	hash, raw := deriveSeed(prev.Timings, cfg, counterMix(mix, cfg, 0))
+	// A mismatch always warns, so --strict sees it; a match is only news
+	// under lite verbosity.
+	if hex.EncodeToString(hash) != prev.Hash {
+		warnf("replayed hash differs from the recorded one (different --key/--salt/--buffer-layout, or the run used --pool or clock jitter)")
+	} else if cfg.verbosity >= VerbosityLite {
+		fmt.Fprintln(diag, "Replayed hash matches the recorded one")
+	}
+
+	seed := new(big.Int).SetBytes(raw)
+	seeds := []*big.Int{seed}
+	entropyBits, degraded := entropyEstimate(len(prev.Timings), cfg)
+	for i := 1; i < cfg.seedCount; i++ {
+		_, r := deriveSeed(prev.Timings, cfg, counterMix(mix, cfg, i))
+		seeds = append(seeds, new(big.Int).SetBytes(r))
+	}
+	return &Result{
+		Timings:       prev.Timings,
+		ExitCodes:     prev.ExitCodes,
+		MaxRSS:        prev.MaxRSS,
+		Counters:      prev.Counters,
+		BinSizes:      prev.BinSizes,
+		CtxSwitches:   prev.CtxSwitches,
+		StderrLengths: prev.StderrLengths,
+		StderrDigests: prev.StderrDigests,
+		CompileTimes:  prev.CompileTimes,
+		Addresses:     prev.Addresses,
+		Beacon:        beacon,
+		BufferLayout:  cfg.bufferLayout,
+		Hash:          hash,
+		Raw:           raw,
+		Seed:          seed,
+		Seeds:         seeds,
+		Rand:          newPRNG(raw, cfg),
+		EntropyBits:   entropyBits,
+		Degraded:      degraded,
+	}, nil
+}
+
+// writeSnapshot saves res to --snapshot-timings in the --json format, for
+// later runs to replay.
+func writeSnapshot(cfg config, res *Result) error {
+	f, err := os.Create(cfg.snapshotTimings)
+	if err != nil {
+		return fmt.Errorf("--snapshot-timings: %w", err)
+	}
+	err = writeJSON(f, cfg, res)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("--snapshot-timings: writing %s: %w", cfg.snapshotTimings, err)
+	}
+	return nil
+}
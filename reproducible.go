@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// jsonBuild is one language's entry in the --verify-reproducible JSON
+// report: the hex blake2b-256 of each build's executable.
+type jsonBuild struct {
+	Identical bool   `json:"identical"`
+	First     string `json:"first"`
+	Second    string `json:"second"`
+}
+
+// verifyReproducible is --verify-reproducible: it builds every compiled
+// task twice, in the same temp directory so the paths baked into the
+// binaries match, and reports whether each language's two executables are
+// byte-identical. Differing builds aren't an error; failed ones are.
+func verifyReproducible(w io.Writer, cfg config) error {
+	tmpdir, err := os.MkdirTemp(cfg.tmpdir, "prandom_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	first, firstErr := buildDigests(tmpdir, cfg)
+	if firstErr != nil && cfg.failFast {
+		return firstErr
+	}
+	second, secondErr := buildDigests(tmpdir, cfg)
+	if secondErr != nil && cfg.failFast {
+		return secondErr
+	}
+
+	builds := make(map[string]jsonBuild, len(first))
+	for lang, a := range first {
+		if b, ok := second[lang]; ok {
+			builds[lang] = jsonBuild{Identical: a == b, First: a, Second: b}
+		}
+	}
+	if err := printBuilds(w, cfg, builds); err != nil {
+		return err
+	}
+	return errors.Join(firstErr, secondErr)
+}
+
+// buildDigests runs compileAll in tmpdir and hashes every executable it
+// produced before the next build can overwrite it.
+func buildDigests(tmpdir string, cfg config) (map[string]string, error) {
+	exes, err := compileAll(tmpdir, cfg)
+	digests := make(map[string]string, len(exes))
+	for lang, exe := range exes {
+		d, herr := fileDigest(exe)
+		if herr != nil {
+			return nil, fmt.Errorf("hashing %s: %w", lang, herr)
+		}
+		digests[lang] = d
+	}
+	return digests, err
+}
+
+// fileDigest is the hex blake2b-256 of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h, _ := blake2b.New256(nil)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// printBuilds writes the --verify-reproducible report, one language per
+// line in language order, or as JSON under --json.
+func printBuilds(w io.Writer, cfg config, builds map[string]jsonBuild) error {
+	if cfg.json {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			SchemaVersion int                  `json:"schemaVersion"`
+			Version       string               `json:"version"`
+			Chaos         string               `json:"chaos"`
+			Builds        map[string]jsonBuild `json:"builds"`
+		}{schemaVersion, version, cfg.chaos, builds})
+	}
+
+	langs := make([]string, 0, len(builds))
+	for lang := range builds {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		b := builds[lang]
+		if b.Identical {
+			fmt.Fprintf(w, "  %-8s identical  %s\n", lang, b.First)
+		} else {
+			fmt.Fprintf(w, "  %-8s differs    %s  %s\n", lang, b.First, b.Second)
+		}
+	}
+	return nil
+}
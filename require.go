@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// toolRequirement is one --require entry: tool must be at least version min.
+type toolRequirement struct {
+	tool string
+	min  string
+}
+
+// requireVersionRe is a --require version: dotted numbers, where unlike
+// versionRe a bare major like 18 is fine.
+var requireVersionRe = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// parseRequirements parses --require, a comma-separated list of
+// tool>=version entries naming the tools preflight knows.
+func parseRequirements(s string) ([]toolRequirement, error) {
+	var reqs []toolRequirement
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tool, min, ok := strings.Cut(entry, ">=")
+		tool, min = strings.TrimSpace(tool), strings.TrimSpace(min)
+		if !ok || tool == "" || !requireVersionRe.MatchString(min) {
+			return nil, fmt.Errorf("--require entries look like node>=18 or rustc>=1.70, got %q", entry)
+		}
+		if !slices.ContainsFunc(toolFlags, func(t struct{ flag, tool string }) bool { return t.tool == tool }) {
+			return nil, fmt.Errorf("--require: unknown tool %q", tool)
+		}
+		reqs = append(reqs, toolRequirement{tool, min})
+	}
+	return reqs, nil
+}
+
+// checkRequirements checks each requirement against the version preflight
+// parsed for that tool. Tools this run doesn't use weren't probed and are
+// skipped, so one --require list can cover both chaos levels.
+func checkRequirements(reqs []toolRequirement, tools map[string]toolStatus) error {
+	var errs []error
+	for _, r := range reqs {
+		t, ok := tools[r.tool]
+		if !ok || !t.Found {
+			continue
+		}
+		if t.Version == "" {
+			errs = append(errs, &ErrToolVersion{Tool: r.tool, Want: r.min})
+			continue
+		}
+		if compareVersions(t.Version, r.min) < 0 {
+			errs = append(errs, &ErrToolVersion{Tool: r.tool, Have: t.Version, Want: r.min})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compareVersions compares two dotted version numbers field by field,
+// treating missing fields as 0, so 1.70 and 1.70.0 are equal.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// selftestRuns is how many back-to-back runs selftest compares.
+const selftestRuns = 5
+
+// selftest runs the pipeline selftestRuns times and reports whether every run
+// produced a distinct seed.
+func selftest(cfg config) bool {
+	seen := make(map[string]bool)
+	for i := 1; i <= selftestRuns; i++ {
+		res, err := Generate(cfg)
+		if err != nil {
+			fmt.Printf("FAIL: run %d/%d: %v\n", i, selftestRuns, err)
+			return false
+		}
+		if cfg.verbosity >= VerbosityLite {
+			fmt.Fprintf(diag, "run %d/%d: %s\n", i, selftestRuns, res.Seed)
+		}
+		seen[res.Seed.String()] = true
+	}
+
+	if len(seen) < selftestRuns {
+		fmt.Printf("FAIL: %d/%d distinct seeds\n", len(seen), selftestRuns)
+		fmt.Println("hint: the timing source may be too coarse here; try a heavier --workload or --chaos high")
+		return false
+	}
+	fmt.Printf("PASS: %d/%d distinct seeds\n", len(seen), selftestRuns)
+	return true
+}
+
+// determinismRuns is how many times checkDeterminism derives from the same
+// input, split across determinismWorkers goroutines.
+const (
+	determinismRuns    = 1000
+	determinismWorkers = 8
+)
+
+// checkDeterminism feeds deriveSeed one constant input over and over, from
+// several goroutines at once, and reports whether every derivation matched
+// the first. It catches map-order or concurrency leaks into the hashing
+// path, which the selftest can't since its inputs always differ.
+func checkDeterminism(cfg config) bool {
+	timings, mix := determinismInput(cfg)
+	wantHash, wantRaw := deriveSeed(timings, cfg, mix)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	mismatches := 0
+	for w := 0; w < determinismWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < determinismRuns/determinismWorkers; i++ {
+				hash, raw := deriveSeed(timings, cfg, mix)
+				if !bytes.Equal(hash, wantHash) || !bytes.Equal(raw, wantRaw) {
+					mu.Lock()
+					mismatches++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if mismatches > 0 {
+		fmt.Printf("FAIL: %d/%d derivations differed from the first\n", mismatches, determinismRuns)
+		return false
+	}
+	fmt.Printf("PASS: %d/%d derivations identical\n", determinismRuns, determinismRuns)
+	return true
+}
+
+// determinismInput is the constant input checkDeterminism derives from:
+// timings for every built-in language plus exit codes, peak memory and the
+// --seed-count counter in the mix.
+func determinismInput(cfg config) (map[string]int64, []byte) {
+	timings := map[string]int64{
+		"c": 11, "cpp": 22, "go": 33, "lua": 44, "node": 55,
+		"perl": 66, "php": 77, "python": 88, "rust": 99,
+	}
+	mix := exitCodeBytes(map[string]int{"go": 1, "lua": 0, "node": 2})
+	mix = append(mix, int64Mix(map[string]int64{"go": 1 << 20, "lua": 1 << 21})...)
+	return timings, counterMix(mix, cfg, 1)
+}
+
+// reorderGuard is --reorder-guard: it rebuilds samples by inserting the
+// languages in ascending and then descending order, as queued and parallel
+// runs would fill it, and checks both derive the same hash. Only the
+// deterministic inputs take part; jitter and the pool would differ anyway.
+func reorderGuard(samples map[string]sample, cfg config) error {
+	langs := make([]string, 0, len(samples))
+	for lang := range samples {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	reversed := slices.Clone(langs)
+	slices.Reverse(reversed)
+
+	var want []byte
+	for _, order := range [][]string{langs, reversed} {
+		re := make(map[string]sample, len(order))
+		timings := make(map[string]int64, len(order))
+		for _, lang := range order {
+			re[lang] = samples[lang]
+			timings[lang] = samples[lang].ns
+		}
+		mix, _ := sampleMix(re, cfg)
+		hash, _ := deriveSeed(timings, cfg, mix)
+		if want == nil {
+			want = hash
+		} else if !bytes.Equal(hash, want) {
+			return errors.New("--reorder-guard: the hash changed with the order the timings were collected in")
+		}
+	}
+	if cfg.verbosity >= VerbosityLite {
+		fmt.Fprintln(diag, "Reorder guard passed")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestReorderGuard(t *testing.T) {
+	samples := map[string]sample{
+		"c":      {ns: 3_100_000, exitCode: 0, maxRSS: 1 << 20, binSize: 16_000, ctxsw: 4, addr: 0x7ffd0010, stderr: []byte("c\n"), stderrLen: 2},
+		"go":     {ns: 5_200_000, exitCode: 1, maxRSS: 3 << 20, binSize: 1_400_000, ctxsw: 9, stderr: []byte("go\n"), stderrLen: 3},
+		"lua":    {ns: 7_300_000, exitCode: 0, maxRSS: 2 << 20, ctxsw: 2, addr: 0x55aa0020},
+		"python": {ns: 11_400_000, exitCode: 2, maxRSS: 9 << 20, ctxsw: 17, addr: 0x7f001230},
+	}
+	cfg := testConfig()
+	cfg.seedBits = 512
+	cfg.mixExitCodes = true
+	cfg.measureMemory = true
+	cfg.mixBinsize = true
+	cfg.mixCtxsw = true
+	cfg.collectStderr = true
+	cfg.mixASLR = true
+
+	langs := make([]string, 0, len(samples))
+	for lang := range samples {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	reversed := slices.Clone(langs)
+	slices.Reverse(reversed)
+
+	// The same samples collected in opposite orders, as the queue and the
+	// parallel runs would fill the map, must hash the same.
+	var want []byte
+	for _, order := range [][]string{langs, reversed} {
+		filled := make(map[string]sample, len(order))
+		for _, lang := range order {
+			filled[lang] = samples[lang]
+		}
+		if err := reorderGuard(filled, cfg); err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		res, err := resultFromSamples(filled, cfg)
+		if err != nil {
+			t.Fatalf("%v: %v", order, err)
+		}
+		if want == nil {
+			want = res.Hash
+		} else if !bytes.Equal(res.Hash, want) {
+			t.Errorf("%v: hash %x, want %x as for %v", order, res.Hash, want, langs)
+		}
+	}
+}
+
+// TestDeterminismInputHash pins the digest of checkDeterminism's input, so
+// any change to the derivation shows up here, not just ones that make it
+// inconsistent with itself. want is blake2b-512 of the v1 buffer as the
+// README lays it out, computed outside ptrsg.
+func TestDeterminismInputHash(t *testing.T) {
+	const want = "56937105836af405d6831f146f41b2db16aae4f60a2e65e6b20fddb613d88cb5" +
+		"88b933a93f1dbcb603cbda5680c83b3c67adf545aa360c3569a529b020465605"
+	cfg := testConfig()
+	timings, mix := determinismInput(cfg)
+	hash, raw := deriveSeed(timings, cfg, mix)
+	if got := hex.EncodeToString(hash); got != want {
+		t.Errorf("hash = %s, want %s", got, want)
+	}
+	if !bytes.Equal(raw, hash[:8]) {
+		t.Errorf("64-bit seed = %x, want the hash's first 8 bytes", raw)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// selfTimePrefix starts the line a self-timed task prints last, followed by
+// how long its own body took in nanoseconds.
+const selfTimePrefix = "ptrsg-elapsed-ns: "
+
+// selfTimers is the prologue and epilogue --exclude-startup wraps around each
+// language's snippet. Languages missing here, lua (os.clock is CPU time and
+// too coarse) and the compiled ones, keep the full wall time.
+var selfTimers = map[string][2]string{
+	"python": {
+		"import time as _ptrsg_t\n_ptrsg_s = _ptrsg_t.perf_counter_ns()\n",
+		"print('" + selfTimePrefix + "' + str(_ptrsg_t.perf_counter_ns() - _ptrsg_s))\n",
+	},
+	"node": {
+		"const __ptrsgStart = process.hrtime.bigint();\n",
+		"console.log('" + selfTimePrefix + "' + (process.hrtime.bigint() - __ptrsgStart));\n",
+	},
+	"php": {
+		"$__ptrsg_s = hrtime(true);\n",
+		"echo '" + selfTimePrefix + "', hrtime(true) - $__ptrsg_s, \"\\n\";\n",
+	},
+	"perl": {
+		"use Time::HiRes qw(clock_gettime CLOCK_MONOTONIC);\nmy $__ptrsg_s = clock_gettime(CLOCK_MONOTONIC);\n",
+		"printf \"" + selfTimePrefix + "%d\\n\", (clock_gettime(CLOCK_MONOTONIC) - $__ptrsg_s) * 1e9;\n",
+	},
+}
+
+// selfTimed wraps code in lang's self-timing prologue and epilogue, or
+// returns it unchanged if lang can't time itself. The prologue goes after a
+// leading <?php so PHP still parses it as code.
+func selfTimed(lang, code string) string {
+	t, ok := selfTimers[lang]
+	if !ok {
+		return code
+	}
+	head := ""
+	if rest, found := strings.CutPrefix(code, "<?php\n"); found {
+		head, code = "<?php\n", rest
+	}
+	return head + t[0] + code + t[1]
+}
+
+// parseSelfTime finds the last self-timing line in a task's stdout.
+func parseSelfTime(out []byte) (int64, bool) {
+	var ns int64
+	found := false
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		rest, ok := strings.CutPrefix(sc.Text(), selfTimePrefix)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64); err == nil && n >= 0 {
+			ns, found = n, true
+		}
+	}
+	return ns, found
+}
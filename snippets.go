@@ -0,0 +1,598 @@
+package main
+
+// workloads lists the --workload values every language has a snippet for.
+var workloads = []string{"sort", "hashmap", "arith", "io"}
+
+// codeMap holds the interpreted-language snippets, keyed by workload and
+// then language.
+var codeMap = map[string]map[string]string{
+	"sort": {
+		"lua": `local t = {}
+for i = 1, 100000 do
+    t[i] = tostring(i) .. i
+end
+table.sort(t)
+`,
+		"python": `lst = [str(i) + str(i*i) for i in range(100000)]
+lst.sort()
+`,
+		"node": `let arr = Array.from({length: 100000}, (_, i) => '' + i + (i*i));
+arr.sort();
+`,
+		"php": `<?php
+$a = [];
+for ($i = 0; $i < 100000; $i++) {
+    $a[] = $i . ($i * $i);
+}
+sort($a, SORT_STRING);
+`,
+		"perl": `my @a;
+for my $i (0 .. 99999) {
+    push @a, $i . ($i * $i);
+}
+my @s = sort @a;
+`,
+	},
+	"hashmap": {
+		"lua": `local m = {}
+for i = 1, 100000 do
+    m[tostring(i)] = i * i
+end
+local s = 0
+for i = 1, 100000 do
+    s = s + m[tostring(i)]
+end
+`,
+		"python": `m = {str(i): i*i for i in range(100000)}
+s = 0
+for i in range(100000):
+    s += m[str(i)]
+`,
+		"node": `const m = new Map();
+for (let i = 0; i < 100000; i++) m.set('' + i, i * i);
+let s = 0;
+for (let i = 0; i < 100000; i++) s += m.get('' + i);
+`,
+		"php": `<?php
+$m = [];
+for ($i = 0; $i < 100000; $i++) {
+    $m['k' . $i] = $i * $i;
+}
+$s = 0;
+for ($i = 0; $i < 100000; $i++) {
+    $s += $m['k' . $i];
+}
+`,
+		"perl": `my %m;
+for my $i (0 .. 99999) {
+    $m{$i} = $i * $i;
+}
+my $s = 0;
+for my $i (0 .. 99999) {
+    $s += $m{$i};
+}
+`,
+	},
+	"arith": {
+		"lua": `local x = 0
+for i = 1, 1000000 do
+    x = (x * 31 + i) % 1000003
+end
+`,
+		"python": `x = 0
+for i in range(1000000):
+    x = (x * 31 + i) % 1000003
+`,
+		"node": `let x = 0;
+for (let i = 0; i < 1000000; i++) x = (x * 31 + i) % 1000003;
+`,
+		"php": `<?php
+$x = 0;
+for ($i = 0; $i < 1000000; $i++) {
+    $x = ($x * 31 + $i) % 1000003;
+}
+`,
+		"perl": `my $x = 0;
+for my $i (0 .. 999999) {
+    $x = ($x * 31 + $i) % 1000003;
+}
+`,
+	},
+	"io": {
+		"lua": `local name = os.tmpname()
+local chunk = string.rep("x", 4096)
+local f = assert(io.open(name, "wb"))
+for i = 1, 256 do
+    f:write(chunk)
+end
+f:close()
+f = assert(io.open(name, "rb"))
+local n = #f:read("*a")
+f:close()
+os.remove(name)
+assert(n == 1048576)
+`,
+		"python": `import os, tempfile
+fd, name = tempfile.mkstemp()
+chunk = b"x" * 4096
+with os.fdopen(fd, "wb") as f:
+    for _ in range(256):
+        f.write(chunk)
+with open(name, "rb") as f:
+    n = len(f.read())
+os.remove(name)
+assert n == 1048576
+`,
+		"node": `const fs = require('fs'), os = require('os'), path = require('path');
+const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ptrsg_'));
+const name = path.join(dir, 'io.tmp');
+const chunk = new Uint8Array(4096).fill(120);
+const fd = fs.openSync(name, 'w');
+for (let i = 0; i < 256; i++) fs.writeSync(fd, chunk);
+fs.closeSync(fd);
+const n = fs.readFileSync(name).length;
+fs.rmSync(dir, {recursive: true});
+if (n !== 1048576) throw new Error('io');
+`,
+		"php": `<?php
+$name = tempnam(sys_get_temp_dir(), 'ptrsg');
+$chunk = str_repeat('x', 4096);
+$f = fopen($name, 'wb');
+for ($i = 0; $i < 256; $i++) {
+    fwrite($f, $chunk);
+}
+fclose($f);
+$n = strlen(file_get_contents($name));
+unlink($name);
+if ($n !== 1048576) {
+    exit(1);
+}
+`,
+		"perl": `use File::Temp qw(tempfile);
+my ($fh, $name) = tempfile();
+binmode $fh;
+my $chunk = 'x' x 4096;
+print $fh $chunk for 1 .. 256;
+close $fh;
+open(my $in, '<:raw', $name) or die "$name: $!";
+my $n = length(do { local $/; <$in> });
+close $in;
+unlink $name;
+die "io" unless $n == 1048576;
+`,
+	},
+}
+
+// extraCodeMap holds the compiled-language snippets, keyed by workload and
+// then language.
+var extraCodeMap = map[string]map[string]string{
+	"sort": {
+		"c": `#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+static int cmp(const void *a, const void *b) {
+    return strcmp(*(char *const *)a, *(char *const *)b);
+}
+int main(void) {
+    char **v = malloc(100000 * sizeof *v);
+    for (long long i = 0; i < 100000; ++i) {
+        v[i] = malloc(24);
+        snprintf(v[i], 24, "%lld%lld", i, i * i);
+    }
+    qsort(v, 100000, sizeof *v, cmp);
+    return 0;
+}
+`,
+		"cpp": `#include <iostream>
+#include <vector>
+#include <string>
+#include <algorithm>
+#include <sstream>
+int main() {
+    std::vector<std::string> v;
+    v.reserve(100000);
+    for (int i = 0; i < 100000; ++i) {
+        std::ostringstream oss;
+        oss << i << i*i;
+        v.push_back(oss.str());
+    }
+    std::sort(v.begin(), v.end());
+    return 0;
+}
+`,
+		"go": `package main
+import (
+    "sort"
+    "strconv"
+)
+func main() {
+    s := make([]string, 100000)
+    for i := 0; i < 100000; i++ {
+        s[i] = strconv.Itoa(i) + strconv.Itoa(i*i)
+    }
+    sort.Strings(s)
+}
+`,
+		"rust": `fn main() {
+    let mut v: Vec<String> = (0u64..100_000)
+        .map(|i| format!("{}{}", i, i * i))
+        .collect();
+    v.sort();
+}
+`,
+		"zig": `const std = @import("std");
+fn lessThan(_: void, a: []u8, b: []u8) bool {
+    return std.mem.lessThan(u8, a, b);
+}
+pub fn main() !void {
+    var arena = std.heap.ArenaAllocator.init(std.heap.page_allocator);
+    defer arena.deinit();
+    const alloc = arena.allocator();
+    var list = std.ArrayList([]u8).init(alloc);
+    var i: u64 = 0;
+    while (i < 100000) : (i += 1) {
+        try list.append(try std.fmt.allocPrint(alloc, "{d}{d}", .{ i, i * i }));
+    }
+    std.mem.sort([]u8, list.items, {}, lessThan);
+}
+`,
+		"swift": `var a: [String] = []
+a.reserveCapacity(100000)
+for i in 0..<100000 {
+    a.append(String(i) + String(i * i))
+}
+a.sort()
+`,
+		"haskell": `import Data.List (sort)
+
+main :: IO ()
+main = do
+    let a = sort [show i ++ show (i * i) | i <- [0 .. 99999 :: Int]]
+    -- Laziness would skip the sort if nothing looked at the result.
+    if length a /= 100000 then error "sort" else return ()
+`,
+	},
+	"hashmap": {
+		"c": `#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#define SLOTS 262144
+static char keys[SLOTS][8];
+static long long vals[SLOTS];
+static unsigned long hash(const char *s) {
+    unsigned long h = 5381;
+    while (*s) h = h * 33 + (unsigned char)*s++;
+    return h;
+}
+static long long *slot(const char *k) {
+    unsigned long i = hash(k) & (SLOTS - 1);
+    while (keys[i][0] && strcmp(keys[i], k) != 0) i = (i + 1) & (SLOTS - 1);
+    if (!keys[i][0]) strcpy(keys[i], k);
+    return &vals[i];
+}
+int main(void) {
+    char k[8];
+    for (long long i = 0; i < 100000; ++i) {
+        snprintf(k, sizeof k, "%lld", i);
+        *slot(k) = i * i;
+    }
+    long long s = 0;
+    for (long long i = 0; i < 100000; ++i) {
+        snprintf(k, sizeof k, "%lld", i);
+        s += *slot(k);
+    }
+    return s < 0 ? 1 : 0;
+}
+`,
+		"cpp": `#include <string>
+#include <unordered_map>
+int main() {
+    std::unordered_map<std::string, long long> m;
+    for (long long i = 0; i < 100000; ++i) {
+        m[std::to_string(i)] = i * i;
+    }
+    long long s = 0;
+    for (long long i = 0; i < 100000; ++i) {
+        s += m[std::to_string(i)];
+    }
+    return s < 0 ? 1 : 0;
+}
+`,
+		"go": `package main
+import "strconv"
+func main() {
+    m := make(map[string]int)
+    for i := 0; i < 100000; i++ {
+        m[strconv.Itoa(i)] = i * i
+    }
+    s := 0
+    for i := 0; i < 100000; i++ {
+        s += m[strconv.Itoa(i)]
+    }
+    _ = s
+}
+`,
+		"rust": `use std::collections::HashMap;
+fn main() {
+    let mut m: HashMap<String, u64> = HashMap::new();
+    for i in 0u64..100_000 {
+        m.insert(i.to_string(), i * i);
+    }
+    let mut s: u64 = 0;
+    for i in 0u64..100_000 {
+        s += m[&i.to_string()];
+    }
+    std::hint::black_box(s);
+}
+`,
+		"zig": `const std = @import("std");
+pub fn main() !void {
+    var arena = std.heap.ArenaAllocator.init(std.heap.page_allocator);
+    defer arena.deinit();
+    const alloc = arena.allocator();
+    var m = std.StringHashMap(u64).init(alloc);
+    var i: u64 = 0;
+    while (i < 100000) : (i += 1) {
+        try m.put(try std.fmt.allocPrint(alloc, "{d}", .{i}), i * i);
+    }
+    var s: u64 = 0;
+    var buf: [20]u8 = undefined;
+    i = 0;
+    while (i < 100000) : (i += 1) {
+        s += m.get(try std.fmt.bufPrint(&buf, "{d}", .{i})).?;
+    }
+    std.mem.doNotOptimizeAway(s);
+}
+`,
+		"swift": `var m: [String: Int] = [:]
+for i in 0..<100000 {
+    m[String(i)] = i * i
+}
+var s = 0
+for i in 0..<100000 {
+    s &+= m[String(i)]!
+}
+precondition(s >= 0)
+`,
+		"haskell": `import qualified Data.Map.Strict as M
+
+main :: IO ()
+main = do
+    let m = M.fromList [(show i, i * i) | i <- [0 .. 99999 :: Int]]
+        s = sum [M.findWithDefault 0 (show i) m | i <- [0 .. 99999 :: Int]]
+    if s < 0 then error "hashmap" else return ()
+`,
+	},
+	"arith": {
+		"c": `int main(void) {
+    long long x = 0;
+    for (long long i = 0; i < 1000000; ++i) {
+        x = (x * 31 + i) % 1000003;
+    }
+    return x < 0 ? 1 : 0;
+}
+`,
+		"cpp": `int main() {
+    long long x = 0;
+    for (long long i = 0; i < 1000000; ++i) {
+        x = (x * 31 + i) % 1000003;
+    }
+    return x < 0 ? 1 : 0;
+}
+`,
+		"go": `package main
+func main() {
+    x := 0
+    for i := 0; i < 1000000; i++ {
+        x = (x*31 + i) % 1000003
+    }
+    _ = x
+}
+`,
+		"rust": `fn main() {
+    let mut x: u64 = 0;
+    for i in 0u64..1_000_000 {
+        x = (x * 31 + i) % 1_000_003;
+    }
+    std::hint::black_box(x);
+}
+`,
+		"zig": `const std = @import("std");
+pub fn main() void {
+    var x: u64 = 0;
+    var i: u64 = 0;
+    while (i < 1000000) : (i += 1) {
+        x = (x * 31 + i) % 1000003;
+    }
+    std.mem.doNotOptimizeAway(x);
+}
+`,
+		"swift": `var x = 0
+for i in 0..<1000000 {
+    x = (x * 31 + i) % 1000003
+}
+precondition(x >= 0)
+`,
+		"haskell": `import Data.List (foldl')
+
+main :: IO ()
+main = do
+    let x = foldl' (\acc i -> (acc * 31 + i) ` + "`mod`" + ` 1000003) 0 [0 .. 999999 :: Int]
+    if x < 0 then error "arith" else return ()
+`,
+	},
+	"io": {
+		"c": `#include <stdio.h>
+#include <string.h>
+int main(void) {
+    static char chunk[4096];
+    memset(chunk, 'x', sizeof chunk);
+    FILE *f = tmpfile();
+    if (!f) return 1;
+    for (int i = 0; i < 256; ++i) {
+        fwrite(chunk, 1, sizeof chunk, f);
+    }
+    fflush(f);
+    rewind(f);
+    long n = 0;
+    size_t r;
+    while ((r = fread(chunk, 1, sizeof chunk, f)) > 0) {
+        n += (long)r;
+    }
+    fclose(f);
+    return n == 1048576 ? 0 : 1;
+}
+`,
+		"cpp": `#include <cstdio>
+#include <vector>
+int main() {
+    std::vector<char> chunk(4096, 'x');
+    std::FILE *f = std::tmpfile();
+    if (!f) return 1;
+    for (int i = 0; i < 256; ++i) {
+        std::fwrite(chunk.data(), 1, chunk.size(), f);
+    }
+    std::fflush(f);
+    std::rewind(f);
+    long n = 0;
+    std::size_t r;
+    while ((r = std::fread(chunk.data(), 1, chunk.size(), f)) > 0) {
+        n += static_cast<long>(r);
+    }
+    std::fclose(f);
+    return n == 1048576 ? 0 : 1;
+}
+`,
+		"go": `package main
+import "os"
+func main() {
+    f, err := os.CreateTemp("", "ptrsg_io_")
+    if err != nil {
+        panic(err)
+    }
+    defer os.Remove(f.Name())
+    chunk := make([]byte, 4096)
+    for i := range chunk {
+        chunk[i] = 'x'
+    }
+    for i := 0; i < 256; i++ {
+        if _, err := f.Write(chunk); err != nil {
+            panic(err)
+        }
+    }
+    f.Close()
+    b, err := os.ReadFile(f.Name())
+    if err != nil || len(b) != 1048576 {
+        panic("io")
+    }
+}
+`,
+		"rust": `use std::io::{Read, Write};
+fn main() {
+    let path = std::env::temp_dir().join(format!("ptrsg_io_{}.tmp", std::process::id()));
+    let chunk = [b'x'; 4096];
+    let mut f = std::fs::File::create(&path).unwrap();
+    for _ in 0..256 {
+        f.write_all(&chunk).unwrap();
+    }
+    drop(f);
+    let mut buf = Vec::new();
+    std::fs::File::open(&path).unwrap().read_to_end(&mut buf).unwrap();
+    std::fs::remove_file(&path).unwrap();
+    assert_eq!(buf.len(), 1 << 20);
+}
+`,
+		"zig": `const std = @import("std");
+pub fn main() !void {
+    var arena = std.heap.ArenaAllocator.init(std.heap.page_allocator);
+    defer arena.deinit();
+    const alloc = arena.allocator();
+    const tmp = std.process.getEnvVarOwned(alloc, "TMPDIR") catch
+        std.process.getEnvVarOwned(alloc, "TEMP") catch
+        try alloc.dupe(u8, "/tmp");
+    var dir = try std.fs.openDirAbsolute(tmp, .{});
+    defer dir.close();
+    const name = try std.fmt.allocPrint(alloc, "ptrsg_io_{d}.tmp", .{std.time.nanoTimestamp()});
+    const chunk = [_]u8{'x'} ** 4096;
+    {
+        const f = try dir.createFile(name, .{});
+        defer f.close();
+        var i: usize = 0;
+        while (i < 256) : (i += 1) {
+            try f.writeAll(&chunk);
+        }
+    }
+    const data = try dir.readFileAlloc(alloc, name, 2 << 20);
+    try dir.deleteFile(name);
+    if (data.len != 1 << 20) return error.ShortRead;
+}
+`,
+		"swift": `import Foundation
+let url = FileManager.default.temporaryDirectory
+    .appendingPathComponent("ptrsg_io_\(ProcessInfo.processInfo.processIdentifier).tmp")
+let chunk = Data(repeating: 120, count: 4096)
+FileManager.default.createFile(atPath: url.path, contents: nil)
+let h = try! FileHandle(forWritingTo: url)
+for _ in 0..<256 {
+    h.write(chunk)
+}
+h.closeFile()
+let n = try! Data(contentsOf: url).count
+try! FileManager.default.removeItem(at: url)
+precondition(n == 1 << 20)
+`,
+		"haskell": `import qualified Data.ByteString as B
+import System.Directory (getTemporaryDirectory, removeFile)
+import System.IO (hClose, openBinaryTempFile)
+
+main :: IO ()
+main = do
+    tmp <- getTemporaryDirectory
+    (path, h) <- openBinaryTempFile tmp "ptrsg_io.tmp"
+    let chunk = B.replicate 4096 120
+    mapM_ (const (B.hPut h chunk)) [1 .. 256 :: Int]
+    hClose h
+    b <- B.readFile path
+    removeFile path
+    if B.length b /= 1048576 then error "io" else return ()
+`,
+	},
+}
+
+// cppParallelSort is the sort snippet for cpp under --cpp-threads: the same
+// strings, sorted with std::execution::par. Where the standard library has
+// no parallel algorithms it compiles to the sequential sort instead.
+const cppParallelSort = `#include <algorithm>
+#include <string>
+#include <vector>
+#if __has_include(<execution>)
+#include <execution>
+#endif
+int main() {
+    std::vector<std::string> v;
+    v.reserve(100000);
+    for (long long i = 0; i < 100000; ++i) {
+        v.push_back(std::to_string(i) + std::to_string(i * i));
+    }
+#if defined(__cpp_lib_parallel_algorithm)
+    std::sort(std::execution::par, v.begin(), v.end());
+#else
+    std::sort(v.begin(), v.end());
+#endif
+    return 0;
+}
+`
+
+// compiledSource is the snippet written for compiled task lang, the cpp one
+// for each --cpp-compilers variant, printing an address under --mix-aslr.
+func compiledSource(lang string, cfg config) string {
+	lang = baseLang(lang)
+	code := extraCodeMap[cfg.workload][lang]
+	if lang == "cpp" && cfg.cppThreads {
+		code = cppParallelSort
+	}
+	if cfg.mixASLR {
+		code = addrPrinting(lang, code)
+	}
+	return code
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// maxStderr caps how much of one task's stderr --collect-stderr keeps, so
+// a chatty tool can't balloon memory. Bytes past it are still counted.
+const maxStderr = 64 << 10
+
+// stderrCapture is an io.Writer keeping the first maxStderr bytes written
+// to it and counting all of them.
+type stderrCapture struct {
+	buf []byte
+	n   int64
+}
+
+func (c *stderrCapture) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	if room := maxStderr - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// stderrDigest is the hex blake2b-256 of captured stderr, as recorded in
+// the result and mixed into the hash.
+func stderrDigest(captured []byte) string {
+	sum := blake2b.Sum256(captured)
+	return hex.EncodeToString(sum[:])
+}
+
+// stderrBytes encodes, in language order, each language's total stderr
+// length as 8 bytes big-endian followed by the 32-byte digest of what was
+// kept of it.
+func stderrBytes(lengths map[string]int64, digests map[string]string) []byte {
+	langs := make([]string, 0, len(lengths))
+	for lang := range lengths {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	var b []byte
+	for _, lang := range langs {
+		b = binary.BigEndian.AppendUint64(b, uint64(lengths[lang]))
+		d, _ := hex.DecodeString(digests[lang])
+		b = append(b, d...)
+	}
+	return b
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// stream is --stream: it writes and compiles the tasks once, then times
+// them again every cfg.stream and reports a fresh seed each round until it
+// gets SIGINT or SIGTERM. The binaries stay in one temp directory for the
+// whole run. With --http the same measurements also back a small HTTP
+// server.
+func stream(cfg config, previous *jsonResult) error {
+	// Signals stay caught until the temp directory is gone, so a second
+	// Ctrl-C can't cut the cleanup short.
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tmpdir, err := os.MkdirTemp(cfg.tmpdir, "prandom_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// As in Generate, kill and reap the tasks before RemoveAll runs.
+	ctx, cancel := context.WithCancel(sigCtx)
+	group := &taskGroup{}
+	ctx = withTaskGroup(ctx, group)
+	defer func() {
+		cancel()
+		group.closeAndWait()
+	}()
+
+	if cfg.verbosity >= VerbosityLite {
+		fmt.Fprintf(diag, "Preparing files in %s...\n", tmpdir)
+	}
+	procMap, err := taskCommands(ctx, tmpdir, cfg)
+	if err != nil {
+		return err
+	}
+	if procMap, err = compileTasks(ctx, tmpdir, procMap, cfg); err != nil {
+		return err
+	}
+
+	svc := &seedService{cfg: cfg, run: func() (*Result, error) {
+		samples, err := runTasks(ctx, procMap, cfg)
+		partial := false
+		if err != nil {
+			if samples, err = keepPartial(samples, withExecHint(err, tmpdir), cfg); err != nil {
+				return nil, err
+			}
+			partial = true
+		}
+		if cfg.mixBinsize {
+			addBinSizes(tmpdir, samples)
+		}
+		if cfg.includeCompileTime {
+			addCompileTimes(samples)
+		}
+		res, err := resultFromSamples(samples, cfg)
+		if res != nil && partial {
+			res.Degraded = true
+		}
+		return res, err
+	}}
+	if cfg.http != "" {
+		wait, err := serveHTTP(ctx, svc)
+		if err != nil {
+			return err
+		}
+		// Runs before the tasks are reaped, so no /seed is left measuring.
+		defer wait()
+		defer cancel()
+	}
+
+	tick := time.NewTicker(cfg.stream)
+	defer tick.Stop()
+	for {
+		res, err := svc.measure()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			err = strictErr(cfg, res)
+		}
+		if err != nil {
+			return err
+		}
+		report(cfg, res, previous)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// startStress starts n goroutines that burn CPU for --stress until the
+// returned function is called, which waits for them to stop.
+func startStress(n int) (stop func()) {
+	var quit atomic.Bool
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x := uint64(1)
+			for !quit.Load() {
+				// A short xorshift burst between checks keeps the
+				// goroutine on-CPU without starving the stop flag.
+				for range 1 << 12 {
+					x ^= x << 13
+					x ^= x >> 7
+					x ^= x << 17
+				}
+			}
+			stressSink.Store(x)
+		}()
+	}
+	return func() {
+		quit.Store(true)
+		wg.Wait()
+	}
+}
+
+// stressSink keeps the stress loops' results live so the compiler can't
+// drop them.
+var stressSink atomic.Uint64
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// warnings counts the advisory warnings printed so far, for --strict.
+var warnings atomic.Int64
+
+// warnf prints an advisory "warning:" line to errOut and counts it, so
+// --strict can fail the run on it.
+func warnf(format string, args ...any) {
+	warnings.Add(1)
+	fmt.Fprintf(errOut, "warning: "+format+"\n", args...)
+}
+
+// strictErr is why --strict fails the run at this point, or nil: any
+// warning printed so far, or res, if there is one yet, being degraded.
+func strictErr(cfg config, res *Result) error {
+	if !cfg.strict {
+		return nil
+	}
+	if n := warnings.Load(); n > 0 {
+		return fmt.Errorf("--strict: failing on the %d warning(s) above", n)
+	}
+	if res != nil && res.Degraded {
+		return fmt.Errorf("--strict: failing on a low-entropy seed (%d sources)", len(res.Timings))
+	}
+	return nil
+}
+
+// failStrict exits with status 1 if strictErr has anything to fail on.
+func failStrict(cfg config, res *Result) {
+	if err := strictErr(cfg, res); err != nil {
+		fmt.Fprintln(errOut, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// taskGroup tracks the task and compiler processes one Generate call has
+// running, so its cleanup can wait for every one of them to be reaped before
+// removing the temp directory. Otherwise a panic mid-run would let RemoveAll
+// race live children that still hold the binaries open, which fails on
+// Windows, or a compiler still writing into the directory.
+type taskGroup struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// start registers a process about to launch. It returns false once the
+// group is closed, and the caller must not start anything.
+func (g *taskGroup) start() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+// done marks a process started with start as reaped.
+func (g *taskGroup) done() { g.wg.Done() }
+
+// closeAndWait stops new processes from starting and waits for the running
+// ones. Cancel their context first so they're killed rather than waited out.
+func (g *taskGroup) closeAndWait() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+	g.wg.Wait()
+}
+
+// joinTaskGroup registers a process about to start with ctx's task group,
+// if it has one, and returns what to call once it's reaped. It fails once
+// the group is closed, and then nothing must be started.
+func joinTaskGroup(ctx context.Context) (done func(), err error) {
+	g := taskGroupFrom(ctx)
+	if g == nil {
+		return func() {}, nil
+	}
+	if !g.start() {
+		return nil, context.Canceled
+	}
+	return g.done, nil
+}
+
+type taskGroupKey struct{}
+
+func withTaskGroup(ctx context.Context, g *taskGroup) context.Context {
+	return context.WithValue(ctx, taskGroupKey{}, g)
+}
+
+func taskGroupFrom(ctx context.Context) *taskGroup {
+	g, _ := ctx.Value(taskGroupKey{}).(*taskGroup)
+	return g
+}
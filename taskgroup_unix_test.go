@@ -0,0 +1,67 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestTaskGroupCleanup runs Generate's cleanup against a task that would
+// sleep for a minute: cancelling and closing the group must kill and reap
+// it before the temp directory it runs in is removed.
+func TestTaskGroupCleanup(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(t.TempDir(), "prandom_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(tmpdir, "pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	group := &taskGroup{}
+	ctx = withTaskGroup(ctx, group)
+	ran := make(chan error, 1)
+	go func() {
+		_, err := timeRun(ctx, []string{"/bin/sh", "-c", "echo $$ > " + pidFile + "; exec sleep 60"}, testConfig())
+		ran <- err
+	}()
+
+	var pid int
+	for deadline := time.Now().Add(10 * time.Second); pid == 0; {
+		if b, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(b), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the task never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	cancel()
+	group.closeAndWait()
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Errorf("closeAndWait took %s; the task was waited out, not killed", waited)
+	}
+	// Reaped, not just signalled: a zombie would still take signal 0.
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("task %d still exists after closeAndWait (kill 0: %v)", pid, err)
+	}
+	if err := os.RemoveAll(tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ran; !errors.Is(err, context.Canceled) {
+		t.Errorf("timeRun returned %v, want context.Canceled", err)
+	}
+
+	if _, err := joinTaskGroup(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("joining a closed group gave %v, want context.Canceled", err)
+	}
+}